@@ -0,0 +1,224 @@
+package graylogger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const (
+	// graylogQueueSize declares the default capacity of the asynchronous GELF queue.
+	graylogQueueSize = 1024
+
+	// graylogWorkers declares the default number of goroutines draining the asynchronous GELF queue.
+	graylogWorkers = 1
+)
+
+// OverflowPolicy declares what happens with a GELF message when the asynchronous queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock blocks the log call until there is free space in the queue.
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDropNewest drops the GELF message which could not be queued.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+
+	// OverflowDropOldest drops the oldest queued GELF message to make room for the new one.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+)
+
+// asyncQueue is a bounded in-memory queue of GELF messages,
+// drained by background worker goroutines.
+//  - messages -> the queued GELF messages
+//  - pending -> the number of queued and in-flight messages
+//  - idle -> closed when pending drops to zero
+//  - running -> the workers are started lazily by the first message and stopped by stop()
+//  - lifecycle -> guards the messages channel against being closed while messages are pushed
+type asyncQueue struct {
+	size     int
+	workers  int
+	overflow OverflowPolicy
+	write    func(context.Context, GELFMessage) error
+	report   func(error, GELFMessage)
+
+	lifecycle sync.RWMutex
+	mu        sync.Mutex
+	messages  chan GELFMessage
+	pending   int
+	idle      chan struct{}
+	running   bool
+	stopped   *sync.WaitGroup
+}
+
+// newAsyncQueue creates an asyncQueue which delivers GELF messages by the write function,
+// and passes the dropped messages to the report function.
+func newAsyncQueue(init Init, write func(context.Context, GELFMessage) error, report func(error, GELFMessage)) *asyncQueue {
+	return &asyncQueue{
+		size:     init.GraylogQueueSize,
+		workers:  init.GraylogWorkers,
+		overflow: init.GraylogOverflow,
+		write:    write,
+		report:   report,
+	}
+}
+
+// push puts a GELF message into the queue, following the overflow policy if the queue is full.
+// With OverflowBlock, the message is dropped if the context is done before there is free space in the queue.
+func (q *asyncQueue) push(ctx context.Context, m GELFMessage) {
+	q.lifecycle.RLock()
+	defer q.lifecycle.RUnlock()
+
+	q.mu.Lock()
+	q.start()
+	messages := q.messages
+	if q.pending == 0 {
+		q.idle = make(chan struct{})
+	}
+	q.pending++
+	q.mu.Unlock()
+
+	switch q.overflow {
+	case OverflowDropNewest:
+		select {
+		case messages <- m:
+		default:
+			q.drop(errQueueFull, m)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case messages <- m:
+				return
+			default:
+			}
+
+			select {
+			case dropped := <-messages:
+				q.drop(errQueueFull, dropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case messages <- m:
+		case <-ctx.Done():
+			q.drop(ctx.Err(), m)
+		}
+	}
+}
+
+// drop reports a GELF message, which has not been queued or has been removed from the queue.
+func (q *asyncQueue) drop(err error, m GELFMessage) {
+	q.done()
+	if q.report != nil {
+		q.report(deliveryError(DeliveryDrop, err), m)
+	}
+}
+
+// start launches the worker goroutines, if they are not running yet.
+// The caller must hold q.mu.
+func (q *asyncQueue) start() {
+	if q.running {
+		return
+	}
+
+	q.messages = make(chan GELFMessage, q.size)
+	q.stopped = &sync.WaitGroup{}
+	q.running = true
+
+	for i := 0; i < q.workers; i++ {
+		q.stopped.Add(1)
+		go q.work(q.messages, q.stopped)
+	}
+}
+
+// work delivers the queued GELF messages until the queue is closed.
+func (q *asyncQueue) work(messages chan GELFMessage, stopped *sync.WaitGroup) {
+	defer stopped.Done()
+	for m := range messages {
+		// The queued messages outlive the context of the log call,
+		// the delivery errors are reported by the write function.
+		_ = q.write(context.Background(), m)
+		q.done()
+	}
+}
+
+// done marks one queued message as delivered or dropped.
+func (q *asyncQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		close(q.idle)
+	}
+}
+
+// flush waits until all queued messages are delivered or the context is done.
+func (q *asyncQueue) flush(ctx context.Context) error {
+	q.mu.Lock()
+	if q.pending == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	idle := q.idle
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop drains the queue and stops the worker goroutines.
+// The workers are started again by the next message.
+func (q *asyncQueue) stop() {
+	_ = q.flush(context.Background())
+
+	q.lifecycle.Lock()
+	q.mu.Lock()
+	stopped := q.stopped
+	if q.running {
+		close(q.messages)
+		q.running = false
+	}
+	q.mu.Unlock()
+	q.lifecycle.Unlock()
+
+	if stopped != nil {
+		stopped.Wait()
+	}
+}
+
+// initAsync sets the defaults of the asynchronous delivery and creates the GELF queue.
+func (g *GrayLogger) initAsync() {
+	if g.initData.GraylogQueueSize <= 0 {
+		g.initData.GraylogQueueSize = graylogQueueSize
+	}
+
+	if g.initData.GraylogWorkers <= 0 {
+		g.initData.GraylogWorkers = graylogWorkers
+	}
+
+	if g.initData.GraylogOverflow == "" {
+		g.initData.GraylogOverflow = OverflowBlock
+	}
+
+	if err := g.initData.GraylogOverflow.validateOverflowPolicy(); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	g.queue = newAsyncQueue(g.initData, g.send, g.reportError)
+}
+
+// validateOverflowPolicy checks that given overflow policy is valid or not.
+func (p OverflowPolicy) validateOverflowPolicy() error {
+	switch p {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		return nil
+	}
+	return fmt.Errorf("invalid overflow policy given: %s", p)
+}
@@ -0,0 +1,323 @@
+package graylogger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type asyncSuite struct {
+	suite.Suite
+}
+
+func (s asyncSuite) TestAsyncSendGELF() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogAsync = true
+	init.GraylogWorkers = 4
+
+	g := New(init)
+	s.Equal(graylogQueueSize, g.GetInit().GraylogQueueSize)
+	s.Equal(OverflowBlock, g.GetInit().GraylogOverflow)
+
+	g.CaptureOutput(testOutputFileName)
+	for i := 0; i < 100; i++ {
+		g.Info("async", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Equal(nil, g.Flush(ctx))
+
+	received := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		received[fmt.Sprint(server.message()["short_message"])] = true
+	}
+	s.Equal(100, len(received))
+	s.Equal(true, received["async :: 99"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s asyncSuite) TestSlowSender() {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	s.Require().Equal(nil, err)
+
+	init := testInit
+	init.GraylogHost = host
+	init.GraylogPort, _ = strconv.Atoi(port)
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportHTTP
+	init.GraylogStructured = true
+	init.GraylogAsync = true
+
+	g := New(init)
+	for i := 0; i < 4; i++ {
+		// The worker is in the middle of the delivery, when the next message is logged.
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		g.Info("async", i)
+		s.Equal(true, time.Since(start) < 100*time.Millisecond, time.Since(start))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Equal(nil, g.Flush(ctx))
+	s.Equal(int32(4), atomic.LoadInt32(&received))
+	s.Equal(nil, g.Close())
+}
+
+func (s asyncSuite) TestParallelWorkers() {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	init := (&httpServer{Server: server}).init(httpSuite{s.Suite}, TransportHTTP)
+	init.GraylogStructured = true
+	init.GraylogAsync = true
+	init.GraylogWorkers = 8
+
+	g := New(init)
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		g.Info("async", i)
+	}
+
+	// The status of the endpoints is not blocked by the sends ...
+	time.Sleep(20 * time.Millisecond)
+	statusStart := time.Now()
+	s.Equal(true, g.Endpoints()[0].Healthy)
+	s.Equal(true, time.Since(statusStart) < 50*time.Millisecond, time.Since(statusStart))
+
+	// ... which are made in parallel by the workers.
+	s.Equal(nil, g.Flush(context.Background()))
+	s.Equal(int32(8), atomic.LoadInt32(&received))
+	s.Equal(true, time.Since(start) < 400*time.Millisecond, time.Since(start))
+	s.Equal(nil, g.Close())
+}
+
+func (s asyncSuite) TestUnreachableHost() {
+	var mu sync.Mutex
+	var reported []error
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = retrySuite{s.Suite}.freePort()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogAsync = true
+	init.GraylogOnError = func(err error, m GELFMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}
+
+	g := New(init)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "async", "unreachable"))
+	s.Equal(nil, g.Flush(context.Background()))
+
+	mu.Lock()
+	s.Require().Equal(1, len(reported))
+	s.Equal(true, isDeliveryOp(reported[0], DeliveryConnect), fmt.Sprint(reported[0]))
+	mu.Unlock()
+
+	s.Equal(nil, g.Close())
+}
+
+func (s asyncSuite) TestFlushWithoutAsync() {
+	g := New(testInit)
+	s.Equal(nil, g.Flush(context.Background()))
+}
+
+func (s asyncSuite) TestOverflowBlock() {
+	q := newBlockingQueue(OverflowBlock)
+
+	q.pushFirst(GELFMessage{ShortMessage: "1"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "2"})
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(context.Background(), GELFMessage{ShortMessage: "3"})
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		s.Fail("push should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(q.release)
+	<-pushed
+	q.stop()
+
+	s.Equal([]string{"1", "2", "3"}, q.delivered())
+	s.Equal(0, len(q.drops()))
+}
+
+func (s asyncSuite) TestOverflowBlockContextDone() {
+	q := newBlockingQueue(OverflowBlock)
+
+	q.pushFirst(GELFMessage{ShortMessage: "1"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	q.push(ctx, GELFMessage{ShortMessage: "3"})
+	s.Equal(context.DeadlineExceeded, ctx.Err())
+
+	close(q.release)
+	q.stop()
+
+	s.Equal([]string{"1", "2"}, q.delivered())
+	s.Equal([]string{"3: GELF drop failed: context deadline exceeded"}, q.drops())
+}
+
+func (s asyncSuite) TestOverflowDropNewest() {
+	q := newBlockingQueue(OverflowDropNewest)
+
+	q.pushFirst(GELFMessage{ShortMessage: "1"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "2"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "3"})
+
+	close(q.release)
+	q.stop()
+
+	s.Equal([]string{"1", "2"}, q.delivered())
+	s.Equal([]string{"3: GELF drop failed: asynchronous GELF queue is full"}, q.drops())
+}
+
+func (s asyncSuite) TestOverflowDropOldest() {
+	q := newBlockingQueue(OverflowDropOldest)
+
+	q.pushFirst(GELFMessage{ShortMessage: "1"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "2"})
+	q.push(context.Background(), GELFMessage{ShortMessage: "3"})
+
+	close(q.release)
+	q.stop()
+
+	s.Equal([]string{"1", "3"}, q.delivered())
+	s.Equal([]string{"2: GELF drop failed: asynchronous GELF queue is full"}, q.drops())
+}
+
+func (s asyncSuite) TestFlushTimeout() {
+	q := newBlockingQueue(OverflowBlock)
+
+	q.pushFirst(GELFMessage{ShortMessage: "1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	s.Equal(context.DeadlineExceeded, q.flush(ctx))
+
+	close(q.release)
+	s.Equal(nil, q.flush(context.Background()))
+	q.stop()
+}
+
+func (s asyncSuite) TestRestartAfterStop() {
+	q := newBlockingQueue(OverflowBlock)
+	close(q.release)
+
+	q.push(context.Background(), GELFMessage{ShortMessage: "1"})
+	q.stop()
+
+	q.push(context.Background(), GELFMessage{ShortMessage: "2"})
+	q.stop()
+
+	s.Equal([]string{"1", "2"}, q.delivered())
+}
+
+func (s asyncSuite) TestValidateOverflowPolicy() {
+	s.Equal(nil, OverflowBlock.validateOverflowPolicy())
+	s.Equal(nil, OverflowDropNewest.validateOverflowPolicy())
+	s.Equal(nil, OverflowDropOldest.validateOverflowPolicy())
+	s.Equal("invalid overflow policy given: bad_policy", fmt.Sprint(OverflowPolicy("bad_policy").validateOverflowPolicy()))
+}
+
+// blockingQueue wraps an asyncQueue with one slot and one worker,
+// whose first delivery blocks until release is closed.
+type blockingQueue struct {
+	*asyncQueue
+	mu       sync.Mutex
+	messages []string
+	dropped  []string
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func newBlockingQueue(overflow OverflowPolicy) *blockingQueue {
+	b := &blockingQueue{started: make(chan struct{}), release: make(chan struct{})}
+	b.asyncQueue = newAsyncQueue(Init{GraylogQueueSize: 1, GraylogWorkers: 1, GraylogOverflow: overflow}, func(_ context.Context, m GELFMessage) error {
+		b.mu.Lock()
+		first := len(b.messages) == 0
+		b.messages = append(b.messages, m.ShortMessage)
+		b.mu.Unlock()
+
+		if first {
+			close(b.started)
+			<-b.release
+		}
+		return nil
+	}, func(err error, m GELFMessage) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.dropped = append(b.dropped, fmt.Sprintf("%s: %s", m.ShortMessage, err))
+	})
+	return b
+}
+
+// pushFirst pushes the first message and waits until the worker is blocked on it.
+func (b *blockingQueue) pushFirst(m GELFMessage) {
+	b.push(context.Background(), m)
+	<-b.started
+}
+
+// drops lists the dropped short messages with their errors in order.
+func (b *blockingQueue) drops() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.dropped...)
+}
+
+// delivered lists the delivered short messages in order.
+func (b *blockingQueue) delivered() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.messages...)
+}
+
+func TestAsyncSuite(t *testing.T) {
+	suite.Run(t, new(asyncSuite))
+}
@@ -0,0 +1,185 @@
+package graylogger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// graylogBreakerCooldown declares the default time the circuit breaker stays open.
+const graylogBreakerCooldown = 30 * time.Second
+
+// errBreakerOpen is the error of the connects and GELF writes rejected by the open circuit breaker.
+var errBreakerOpen = errors.New("circuit breaker of the Graylog host is open")
+
+// BreakerState declares the state of the circuit breaker around the Graylog host.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every connect and GELF write through.
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen rejects every connect and GELF write, until the cooldown is elapsed.
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen lets one probe through: its success closes the breaker, its failure opens it again.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// circuitBreaker stops the connects and GELF writes after Init.GraylogBreakerThreshold consecutive failures
+// for Init.GraylogBreakerCooldown, so the log calls do not wait for an unreachable Graylog host.
+//  - failures -> the number of consecutive failures in BreakerClosed state
+//  - openedAt -> when the breaker was opened last time
+//  - probing -> a probe is in flight in BreakerHalfOpen state
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// initBreaker sets the defaults of the circuit breaker and creates it, if Init.GraylogBreakerThreshold is set.
+func (g *GrayLogger) initBreaker() {
+	if g.initData.GraylogBreakerThreshold != 0 && g.initData.GraylogBreakerCooldown == 0 {
+		g.initData.GraylogBreakerCooldown = graylogBreakerCooldown
+	}
+
+	if err := validateBreakerThreshold(g.initData.GraylogBreakerThreshold); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	if err := validateBreakerCooldown(g.initData.GraylogBreakerCooldown); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	if g.initData.GraylogBreakerThreshold > 0 {
+		g.breaker = &circuitBreaker{
+			threshold: g.initData.GraylogBreakerThreshold,
+			cooldown:  g.initData.GraylogBreakerCooldown,
+			state:     BreakerClosed,
+		}
+	}
+}
+
+// BreakerState returns with the state of the circuit breaker around the Graylog host.
+// The open breaker turns into BreakerHalfOpen by the first connect or GELF write after the cooldown.
+// Without Init.GraylogBreakerThreshold it is always BreakerClosed.
+func (g *GrayLogger) BreakerState() BreakerState {
+	if g.breaker == nil {
+		return BreakerClosed
+	}
+
+	g.breaker.mu.Lock()
+	defer g.breaker.mu.Unlock()
+
+	return g.breaker.state
+}
+
+// guarded calls f through the circuit breaker, if it is enabled.
+// The rejected calls return with a *DeliveryError of DeliveryConnect, and the state transitions are logged once.
+func (g *GrayLogger) guarded(f func() error) error {
+	if g.breaker == nil {
+		return f()
+	}
+
+	allowed, changed := g.breaker.allow(time.Now())
+	if changed {
+		g.logBreakerState(BreakerHalfOpen)
+	}
+	if !allowed {
+		return deliveryError(DeliveryConnect, errBreakerOpen)
+	}
+
+	err := f()
+	if state, changed := g.breaker.record(err, time.Now()); changed {
+		g.logBreakerState(state)
+	}
+	return err
+}
+
+// logBreakerState writes the new state of the circuit breaker to stdOut.
+func (g *GrayLogger) logBreakerState(state BreakerState) {
+	switch state {
+	case BreakerOpen:
+		g.functions.Error.Println(g.formatLogLine(levelErrorNum, "Graylog circuit breaker", state, "cooldown", g.initData.GraylogBreakerCooldown))
+	case BreakerHalfOpen:
+		g.functions.Warning.Println(g.formatLogLine(levelWarningNum, "Graylog circuit breaker", state))
+	default:
+		g.functions.Info.Println(g.formatLogLine(levelInfoNum, "Graylog circuit breaker", state))
+	}
+}
+
+// allow checks that a call may go through the breaker, and whether the breaker has turned into BreakerHalfOpen.
+// Only one probe is let through in BreakerHalfOpen state.
+func (b *circuitBreaker) allow(now time.Time) (allowed, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+		changed = true
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return false, changed
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, changed
+		}
+		b.probing = true
+	}
+	return true, changed
+}
+
+// record updates the breaker by the result of a call, and returns with its new state and whether it has changed.
+// The encoding errors, the errors of a done context (e.g. the deadline of a log call) and the responses of the GELF HTTP input
+// do not tell that the Graylog host is unreachable, so they are not counted.
+func (b *circuitBreaker) record(err error, now time.Time) (BreakerState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.state
+	b.probing = false
+
+	switch {
+	case isDeliveryOp(err, DeliveryEncode), isContextError(err), isHTTPStatusError(err):
+	case err == nil:
+		b.state = BreakerClosed
+		b.failures = 0
+	case b.state == BreakerHalfOpen:
+		b.state = BreakerOpen
+		b.openedAt = now
+	case b.state == BreakerOpen:
+		// The failure of a call let through before the breaker was opened.
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = BreakerOpen
+			b.openedAt = now
+			b.failures = 0
+		}
+	}
+	return b.state, b.state != previous
+}
+
+// validateBreakerThreshold checks that given circuit breaker threshold is valid or not.
+func validateBreakerThreshold(threshold int) error {
+	if threshold < 0 {
+		return fmt.Errorf("invalid circuit breaker threshold given: %d", threshold)
+	}
+	return nil
+}
+
+// validateBreakerCooldown checks that given circuit breaker cooldown is valid or not.
+func validateBreakerCooldown(cooldown time.Duration) error {
+	if cooldown < 0 {
+		return fmt.Errorf("invalid circuit breaker cooldown given: %s", cooldown)
+	}
+	return nil
+}
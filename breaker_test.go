@@ -0,0 +1,157 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type breakerSuite struct {
+	suite.Suite
+}
+
+func (s breakerSuite) TestBreaker() {
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = retrySuite{s.Suite}.freePort()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogBreakerThreshold = 2
+	init.GraylogBreakerCooldown = 100 * time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	// 1. The breaker is opened by the second failure, the next calls are rejected ...
+	for i := 0; i < 5; i++ {
+		err := g.SendGELF(levelInfoNum, "test", i)
+		s.Equal(true, isDeliveryOp(err, DeliveryConnect))
+		s.Equal(i >= 2, errors.Is(err, errBreakerOpen))
+	}
+	s.Equal(BreakerOpen, g.BreakerState())
+	s.Equal(BreakerOpen, g.With("child", true).BreakerState())
+
+	// 2. ... and closed by the successful probe after the cooldown.
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", init.GraylogPort))
+	s.Require().Equal(nil, err)
+	server := newListenerServer(graylogSuite{s.Suite}, l)
+	defer server.close()
+
+	time.Sleep(init.GraylogBreakerCooldown)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "test", "probe"))
+	s.Equal("test :: probe", server.message()["short_message"])
+	s.Equal(BreakerClosed, g.BreakerState())
+
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	output, err := ioutil.ReadFile(testOutputFileName)
+	s.Equal(nil, err)
+	s.Equal(2, strings.Count(string(output), "could not connect to Graylog host"))
+	s.Equal(1, strings.Count(string(output), "[Graylog circuit breaker :: open :: cooldown :: 100ms]"))
+	s.Equal(1, strings.Count(string(output), "[Graylog circuit breaker :: half_open]"))
+	s.Equal(1, strings.Count(string(output), "[Graylog circuit breaker :: closed]"))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s breakerSuite) TestTransitions() {
+	failure := errors.New("connection refused")
+	now := time.Now()
+	b := &circuitBreaker{threshold: 2, cooldown: time.Minute, state: BreakerClosed}
+
+	// 1. The failures below the threshold, the encoding errors, the done contexts and the HTTP responses keep the breaker closed ...
+	s.Equal(true, s.allowed(b, now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, failure, now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, deliveryError(DeliveryEncode, failure), now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, deliveryError(DeliveryWrite, context.DeadlineExceeded), now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, fmt.Errorf("post: %w", context.Canceled), now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, deliveryError(DeliveryWrite, &httpStatusError{status: 400}), now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, nil, now))
+	s.Equal([]interface{}{BreakerClosed, false}, s.record(b, failure, now))
+
+	// 2. ... the threshold opens it, until the cooldown is elapsed ...
+	s.Equal([]interface{}{BreakerOpen, true}, s.record(b, failure, now))
+	s.Equal(false, s.allowed(b, now.Add(time.Second)))
+
+	// 3. ... then only one probe is let through, whose failure opens it again ...
+	allowed, changed := b.allow(now.Add(time.Minute))
+	s.Equal(true, allowed)
+	s.Equal(true, changed)
+	s.Equal(false, s.allowed(b, now.Add(time.Minute)))
+	s.Equal([]interface{}{BreakerOpen, true}, s.record(b, failure, now.Add(time.Minute)))
+	s.Equal(false, s.allowed(b, now.Add(time.Minute+time.Second)))
+
+	// 4. ... and whose success closes it.
+	s.Equal(true, s.allowed(b, now.Add(2*time.Minute)))
+	s.Equal([]interface{}{BreakerClosed, true}, s.record(b, nil, now.Add(2*time.Minute)))
+	s.Equal(true, s.allowed(b, now.Add(2*time.Minute)))
+}
+
+func (s breakerSuite) TestDeadlineOfLogCall() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	init := (&httpServer{Server: server}).init(httpSuite{s.Suite}, TransportHTTP)
+	init.GraylogStructured = true
+	init.GraylogBreakerThreshold = 1
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	err := g.SendGELFContext(ctx, levelInfoNum, "test", "deadline")
+	cancel()
+	g.SaveOutput()
+
+	s.Equal(true, isContextError(err), fmt.Sprint(err))
+	s.Equal(BreakerClosed, g.BreakerState())
+	s.Equal(nil, g.Close())
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s breakerSuite) TestInitBreaker() {
+	g := New(Init{GraylogProtocol: TransportTCP, LogLevel: LevelDebug})
+	s.Equal(time.Duration(0), g.GetInit().GraylogBreakerCooldown)
+	s.Equal(BreakerClosed, g.BreakerState())
+	s.Equal(nil, g.guarded(func() error { return nil }))
+
+	g = New(Init{GraylogProtocol: TransportTCP, GraylogBreakerThreshold: 3, LogLevel: LevelDebug})
+	s.Equal(graylogBreakerCooldown, g.GetInit().GraylogBreakerCooldown)
+	s.Equal(BreakerClosed, g.BreakerState())
+
+	s.Equal(nil, validateBreakerThreshold(0))
+	s.Equal("invalid circuit breaker threshold given: -1", fmt.Sprint(validateBreakerThreshold(-1)))
+	s.Equal(nil, validateBreakerCooldown(time.Second))
+	s.Equal("invalid circuit breaker cooldown given: -1s", fmt.Sprint(validateBreakerCooldown(-time.Second)))
+}
+
+func (s breakerSuite) allowed(b *circuitBreaker, now time.Time) bool {
+	allowed, _ := b.allow(now)
+	return allowed
+}
+
+func (s breakerSuite) record(b *circuitBreaker, err error, now time.Time) []interface{} {
+	state, changed := b.record(err, now)
+	return []interface{}{state, changed}
+}
+
+func TestBreakerSuite(t *testing.T) {
+	suite.Run(t, new(breakerSuite))
+}
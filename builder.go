@@ -0,0 +1,65 @@
+package graylogger
+
+import (
+	"sort"
+)
+
+// GELFBuilder customizes the GELF messages sent to Graylog.
+// It is called with the Record of the log call and with a GELF message built by default,
+// and the returned message is sent instead. With Init.GraylogStructured disabled, it is called for every key : value pair.
+// The message can be changed freely, e.g.:
+//  - ShortMessage and FullMessage can be composed from the fields of the Record
+//  - the additional fields (e.g. log_env or track_function) can be renamed or deleted from Extra
+//  - static fields can be added to Extra
+// The names of the additional fields must be valid GELF field names without the leading underscore.
+// The Extra map belongs to the message, so it can be modified in place.
+// For example:
+//  func(r graylogger.Record, m graylogger.GELFMessage) graylogger.GELFMessage {
+//      m.Extra["environment"] = m.Extra["log_env"]
+//      delete(m.Extra, "log_env")
+//      return m
+//  }
+type GELFBuilder func(r Record, m GELFMessage) GELFMessage
+
+// RenameGELFFields returns a GELFBuilder, which renames the additional fields of the GELF messages
+// from the map keys to the map values. The fields renamed to an empty name are deleted.
+// The fields are renamed in the order of their names, the missing fields are skipped.
+// For example:
+//  graylogger.RenameGELFFields(map[string]string{"log_env": "environment", "track_line": ""})
+func RenameGELFFields(names map[string]string) GELFBuilder {
+	from := make([]string, 0, len(names))
+	for name := range names {
+		from = append(from, name)
+	}
+	sort.Strings(from)
+
+	return func(_ Record, m GELFMessage) GELFMessage {
+		for _, name := range from {
+			value, exists := m.Extra[name]
+			if !exists {
+				continue
+			}
+
+			delete(m.Extra, name)
+			if to := names[name]; to != "" {
+				m.Extra[to] = value
+			}
+		}
+		return m
+	}
+}
+
+// ChainGELFBuilders returns a GELFBuilder, which customizes the GELF messages
+// by all of the given builders, one after the other.
+// For example:
+//  graylogger.ChainGELFBuilders(graylogger.RenameGELFFields(names), shortMessageBuilder)
+func ChainGELFBuilders(builders ...GELFBuilder) GELFBuilder {
+	return func(r Record, m GELFMessage) GELFMessage {
+		for _, builder := range builders {
+			if builder != nil {
+				m = builder(r, m)
+			}
+		}
+		return m
+	}
+}
@@ -0,0 +1,109 @@
+package graylogger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type builderSuite struct {
+	suite.Suite
+}
+
+func (s builderSuite) TestGELFBuilder() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStructured = true
+	init.GraylogGELFBuilder = ChainGELFBuilders(
+		RenameGELFFields(map[string]string{"log_env": "environment", "track_line": "", "track_file": ""}),
+		func(r Record, m GELFMessage) GELFMessage {
+			m.ShortMessage = fmt.Sprint(r.Fields[0].Value)
+			m.FullMessage = fmt.Sprintf("%s:%s %s", r.Caller.File, r.Caller.Line, m.FullMessage)
+			m.Extra["team"] = "payments"
+			return m
+		},
+	)
+
+	g := New(init).With("component", "db")
+	g.Info("msg", "order created", "order", "A-1")
+	s.Equal(nil, g.Close())
+
+	m := server.message()
+	s.Equal("order created", m["short_message"])
+	s.Regexp(`^builder_test\.go:\d+ msg :: order created :: order :: A-1$`, m["full_message"])
+	s.Equal("test", m["_environment"])
+	s.Equal("payments", m["_team"])
+	s.Equal("A-1", m["_order"])
+	s.Equal("db", m["_component"])
+	s.Equal("graylogger.builderSuite.TestGELFBuilder", m["_track_function"])
+	s.Equal(nil, m["_log_env"])
+	s.Equal(nil, m["_track_line"])
+	s.Equal(nil, m["_track_file"])
+	s.Equal("TestService", m["host"])
+	s.Equal(float64(levelInfoNum), m["level"])
+}
+
+func (s builderSuite) TestMessagePerPair() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	var mu sync.Mutex
+	var keys []string
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogGELFBuilder = func(r Record, m GELFMessage) GELFMessage {
+		mu.Lock()
+		defer mu.Unlock()
+
+		s.Equal(LevelWarning, r.Level)
+		s.Equal(3, len(r.Fields))
+		keys = append(keys, m.Extra["log_key"])
+
+		m.ShortMessage = strings.TrimSpace(m.Extra["log_value"])
+		return m
+	}
+
+	g := New(init).With("component", "db")
+	g.Warning("user", 42, "status", "failed")
+	s.Equal(nil, g.Close())
+
+	s.Equal("42", server.message()["short_message"])
+	s.Equal("failed", server.message()["short_message"])
+
+	mu.Lock()
+	s.Equal([]string{"user", "status"}, keys)
+	mu.Unlock()
+}
+
+func (s builderSuite) TestRenameGELFFields() {
+	rename := RenameGELFFields(map[string]string{"a": "b", "b": "c", "missing": "x", "drop": ""})
+
+	m := rename(Record{}, GELFMessage{Extra: map[string]string{"a": "1", "b": "2", "drop": "3", "keep": "4"}})
+	s.Equal(map[string]string{"c": "1", "keep": "4"}, m.Extra)
+
+	m = rename(Record{}, GELFMessage{ShortMessage: "no extra"})
+	s.Equal(GELFMessage{ShortMessage: "no extra"}, m)
+
+	chained := ChainGELFBuilders(nil, rename, func(_ Record, m GELFMessage) GELFMessage {
+		m.ShortMessage += " :: " + m.Extra["c"]
+		return m
+	})
+	m = chained(Record{}, GELFMessage{ShortMessage: "renamed", Extra: map[string]string{"a": "1"}})
+	s.Equal("renamed :: 1", m.ShortMessage)
+}
+
+func TestBuilderSuite(t *testing.T) {
+	suite.Run(t, new(builderSuite))
+}
@@ -0,0 +1,107 @@
+package graylogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Compression declares how the GELF payloads are compressed.
+// GELF allows compression with TransportUDP, TransportHTTP and TransportHTTPS,
+// the GELF messages sent by TransportTCP and TransportTLS are never compressed.
+type Compression string
+
+const (
+	// CompressionNone sends the GELF payloads uncompressed.
+	CompressionNone Compression = "none"
+
+	// CompressionGzip compresses the GELF payloads with gzip.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionZlib compresses the GELF payloads with zlib (HTTP: deflate).
+	CompressionZlib Compression = "zlib"
+)
+
+// initCompression sets the defaults of the GELF compression.
+// The compression level 0 is the unset level, so it means gzip.DefaultCompression instead of gzip.NoCompression:
+// the payloads are sent uncompressed by CompressionNone.
+func (g *GrayLogger) initCompression() {
+	if g.initData.GraylogCompression == "" {
+		g.initData.GraylogCompression = CompressionNone
+	}
+
+	if g.initData.GraylogCompressionLevel == 0 {
+		g.initData.GraylogCompressionLevel = gzip.DefaultCompression
+	}
+
+	if err := g.initData.GraylogCompression.validateCompression(); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	if err := validateCompressionLevel(g.initData.GraylogCompressionLevel); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+}
+
+// compress compresses the payload by given compression level.
+// With CompressionNone, the payload is returned as it is.
+func (c Compression) compress(payload []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+
+	switch c {
+	case CompressionGzip:
+		w, err = gzip.NewWriterLevel(&buf, level)
+	case CompressionZlib:
+		w, err = zlib.NewWriterLevel(&buf, level)
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// contentEncoding returns with the value of the Content-Encoding HTTP header of the compression.
+func (c Compression) contentEncoding() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZlib:
+		return "deflate"
+	}
+	return ""
+}
+
+// validateCompression checks that given compression is valid or not.
+func (c Compression) validateCompression() error {
+	switch c {
+	case CompressionNone, CompressionGzip, CompressionZlib:
+		return nil
+	}
+	return fmt.Errorf("invalid compression given: %s", c)
+}
+
+// validateCompressionLevel checks that given compression level is valid or not.
+// gzip.NoCompression is rejected, the uncompressed payloads are sent by CompressionNone.
+func validateCompressionLevel(level int) error {
+	switch {
+	case level == gzip.HuffmanOnly, level == gzip.DefaultCompression:
+		return nil
+	case level >= gzip.BestSpeed && level <= gzip.BestCompression:
+		return nil
+	}
+	return fmt.Errorf("invalid compression level given: %d", level)
+}
@@ -0,0 +1,152 @@
+package graylogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type compressionSuite struct {
+	suite.Suite
+}
+
+func (s compressionSuite) TestUDPRoundTrip() {
+	numbers := fmt.Sprint(rand.New(rand.NewSource(1)).Perm(500))
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZlib} {
+		server := newChunkServer(udpSuite{s.Suite})
+
+		init := server.init()
+		init.GraylogUDPChunkSize = 100
+		init.GraylogCompression = compression
+		init.GraylogCompressionLevel = gzip.BestCompression
+
+		g := New(init)
+		g.CaptureOutput(testOutputFileName)
+
+		g.Info("test", compression)
+		obj, _ := server.message()
+		s.Equal(fmt.Sprintf("test :: %s", compression), obj["short_message"], compression)
+
+		// The compressed messages are chunked as well.
+		g.Info("test", numbers)
+		obj, chunks := server.message()
+		s.Equal("test :: "+numbers, obj["full_message"], compression)
+		s.Equal(true, chunks > 1, compression)
+
+		s.Equal(nil, g.Close())
+		g.SaveOutput()
+		server.close()
+	}
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s compressionSuite) TestHTTPRoundTrip() {
+	server := newHTTPServer(http.StatusAccepted)
+	defer server.Close()
+
+	for compression, encoding := range map[Compression]string{
+		CompressionNone: "",
+		CompressionGzip: "gzip",
+		CompressionZlib: "deflate",
+	} {
+		init := server.init(httpSuite{s.Suite}, TransportHTTP)
+		init.GraylogCompression = compression
+
+		g := New(init)
+		g.CaptureOutput(testOutputFileName)
+		g.Info("test", compression)
+		g.SaveOutput()
+
+		requests := server.requests()
+		r := requests[len(requests)-1]
+		s.Equal(encoding, r.header.Get("Content-Encoding"))
+		s.Equal(fmt.Sprintf("test :: %s", compression), r.body["short_message"])
+
+		s.Equal(nil, g.Close())
+	}
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s compressionSuite) TestCompress() {
+	payload := []byte(strings.Repeat(`{"short_message":"compress"}`, 10))
+
+	compressed, err := CompressionNone.compress(payload, gzip.DefaultCompression)
+	s.Equal(nil, err)
+	s.Equal(payload, compressed)
+
+	compressed, err = CompressionGzip.compress(payload, gzip.BestSpeed)
+	s.Equal(nil, err)
+	s.Equal(true, len(compressed) < len(payload))
+	decompressed, err := decompressGELF(compressed)
+	s.Equal(nil, err)
+	s.Equal(payload, decompressed)
+
+	compressed, err = CompressionZlib.compress(payload, gzip.BestCompression)
+	s.Equal(nil, err)
+	decompressed, err = decompressGELF(compressed)
+	s.Equal(nil, err)
+	s.Equal(payload, decompressed)
+
+	_, err = CompressionGzip.compress(payload, 42)
+	s.NotEqual(nil, err)
+}
+
+func (s compressionSuite) TestInitCompression() {
+	g := New(Init{LogLevel: LevelDebug})
+	s.Equal(CompressionNone, g.GetInit().GraylogCompression)
+	s.Equal(gzip.DefaultCompression, g.GetInit().GraylogCompressionLevel)
+
+	s.Equal(nil, CompressionNone.validateCompression())
+	s.Equal(nil, CompressionGzip.validateCompression())
+	s.Equal(nil, CompressionZlib.validateCompression())
+	s.Equal("invalid compression given: bad_compression", fmt.Sprint(Compression("bad_compression").validateCompression()))
+
+	s.Equal(nil, validateCompressionLevel(gzip.BestSpeed))
+	s.Equal(nil, validateCompressionLevel(gzip.BestCompression))
+	s.Equal(nil, validateCompressionLevel(gzip.HuffmanOnly))
+	s.Equal(nil, validateCompressionLevel(gzip.DefaultCompression))
+	s.Equal("invalid compression level given: 10", fmt.Sprint(validateCompressionLevel(10)))
+	s.Equal("invalid compression level given: 0", fmt.Sprint(validateCompressionLevel(gzip.NoCompression)))
+	s.Equal("invalid compression level given: -3", fmt.Sprint(validateCompressionLevel(-3)))
+
+	g = New(Init{LogLevel: LevelDebug, GraylogCompression: CompressionGzip, GraylogCompressionLevel: gzip.NoCompression})
+	s.Equal(gzip.DefaultCompression, g.GetInit().GraylogCompressionLevel)
+}
+
+// decompressGELF decompresses a gzip or zlib compressed GELF payload,
+// the uncompressed payloads are returned as they are.
+func decompressGELF(payload []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case len(payload) > 0 && payload[0] == 0x78:
+		r, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	return payload, nil
+}
+
+func TestCompressionSuite(t *testing.T) {
+	suite.Run(t, new(compressionSuite))
+}
@@ -0,0 +1,105 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// ContextExtractor pulls key/value pairs (e.g. trace or user IDs) out of a context.Context,
+// which are added to the log line and to the GELF message of the *Context logger functions.
+// For example:
+//  func(ctx context.Context) []interface{} {
+//      return []interface{}{"user_id", ctx.Value(userIDKey)}
+//  }
+type ContextExtractor func(ctx context.Context) []interface{}
+
+// ContextValues returns a ContextExtractor, which pulls the values registered
+// under the given context keys into the additional fields named by the map keys.
+// The context keys without value are skipped.
+// For example:
+//  graylogger.ContextValues(map[string]interface{}{"request_id": requestIDKey})
+func ContextValues(fields map[string]interface{}) ContextExtractor {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(ctx context.Context) []interface{} {
+		keysAndValues := make([]interface{}, 0, 2*len(names))
+		for _, name := range names {
+			if value := ctx.Value(fields[name]); value != nil {
+				keysAndValues = append(keysAndValues, name, value)
+			}
+		}
+		return keysAndValues
+	}
+}
+
+// ChainContextExtractors returns a ContextExtractor, which pulls the key/value pairs
+// out of the context by all of the given extractors, one after the other.
+// For example:
+//  graylogger.ChainContextExtractors(otelgraylogger.Extractor, userExtractor)
+func ChainContextExtractors(extractors ...ContextExtractor) ContextExtractor {
+	return func(ctx context.Context) []interface{} {
+		var keysAndValues []interface{}
+		for _, extractor := range extractors {
+			if extractor != nil {
+				keysAndValues = append(keysAndValues, extractor(ctx)...)
+			}
+		}
+		return keysAndValues
+	}
+}
+
+// contextFields returns with the key/value pairs pulled out of the context by Init.GraylogContextExtractor.
+func (g *GrayLogger) contextFields(ctx context.Context) []keyValue {
+	if g.initData.GraylogContextExtractor == nil {
+		return nil
+	}
+	return keysAndValuesToPairs(g.initData.GraylogContextExtractor(ctx))
+}
+
+// boundFields returns with the fields pulled out of the context and the fields of the child logger,
+// which are added to every log line and GELF message.
+func (g *GrayLogger) boundFields(ctx context.Context) []keyValue {
+	return append(g.contextFields(ctx), g.fields...)
+}
+
+// isContextError checks that the error is caused by a cancelled context or by its deadline.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// watchContext applies the deadline of the context to the I/O of the connection,
+// and interrupts the blocked I/O when the context is done.
+// The returned function detaches the context from the connection.
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// A deadline in the past makes the blocked I/O return immediately.
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		_ = conn.SetDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,223 @@
+package graylogger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type contextSuite struct {
+	suite.Suite
+}
+
+type contextKey string
+
+func (s contextSuite) TestContextExtractor() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogContextExtractor = ContextValues(map[string]interface{}{
+		"user_id":  contextKey("user"),
+		"trace_id": contextKey("trace"),
+	})
+
+	ctx := context.WithValue(context.Background(), contextKey("user"), 42)
+	ctx = context.WithValue(ctx, contextKey("trace"), "4bf92f35")
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	g.InfoContext(ctx, "order", "A-1")
+	obj := server.message()
+	s.Equal("order :: A-1", obj["short_message"])
+	s.Equal("42", obj["_user_id"])
+	s.Equal("4bf92f35", obj["_trace_id"])
+	s.Equal("info", obj["_log_level"])
+	s.Equal("context_test.go", obj["_track_file"])
+
+	g.DebugContext(ctx, "debug", 1)
+	s.Equal("debug", server.message()["_log_level"])
+	g.WarningContext(ctx, "warning", 1)
+	s.Equal("warning", server.message()["_log_level"])
+	g.ErrorContext(ctx, "error", 1)
+	s.Equal("error", server.message()["_log_level"])
+
+	// The logger functions without context have no context fields.
+	g.Info("order", "A-2")
+	obj = server.message()
+	s.Equal("order :: A-2", obj["short_message"])
+	s.Equal(nil, obj["_user_id"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	output := g.GetOutput()
+	s.Equal(true, strings.Contains(output, "[DEBUG] "))
+	s.Equal(true, strings.Contains(output, "[ERROR] "))
+	s.Equal(true, strings.Contains(output, "function: graylogger.contextSuite.TestContextExtractor"))
+	s.Equal(true, strings.Contains(output, "[order :: A-1 :: trace_id :: 4bf92f35 :: user_id :: 42]"))
+	s.Equal(true, strings.Contains(output, "[order :: A-2]"))
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s contextSuite) TestContextExtractedOnce() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	var calls int32
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogContextExtractor = func(ctx context.Context) []interface{} {
+		return []interface{}{"sample", atomic.AddInt32(&calls, 1)}
+	}
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.InfoContext(context.Background(), "order", "A-1")
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	s.Equal(int32(1), atomic.LoadInt32(&calls))
+	s.Equal("1", server.message()["_sample"])
+	s.Equal(true, strings.Contains(g.GetOutput(), "[order :: A-1 :: sample :: 1]"), g.GetOutput())
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s contextSuite) TestCancelledContext() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.InfoContext(ctx, "test", "cancelled")
+	g.InfoContext(context.Background(), "test", "sent")
+	g.SaveOutput()
+
+	s.Equal("test :: sent", server.message()["short_message"])
+	s.Equal(true, strings.Contains(g.GetOutput(), "[test :: cancelled]"))
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s contextSuite) TestDeadlineOverHTTP() {
+	release := make(chan struct{})
+	server := &httpServer{statuses: []int{http.StatusAccepted}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		server.handler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	g := New(server.init(httpSuite{s.Suite}, TransportHTTP))
+	g.CaptureOutput(testOutputFileName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	g.SendGELFContext(ctx, levelInfoNum, "test", "deadline")
+	s.Equal(true, time.Since(start) < graylogHTTPTimeout)
+	s.Equal(context.DeadlineExceeded, ctx.Err())
+
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s contextSuite) TestWatchContext() {
+	client, server := net.Pipe()
+	defer func() {
+		_ = client.Close()
+		_ = server.Close()
+	}()
+
+	// 1. The blocked write is interrupted when the context is done ...
+	ctx, cancel := context.WithCancel(context.Background())
+	release := watchContext(ctx, client)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := client.Write([]byte("blocked"))
+	s.NotEqual(nil, err)
+	release()
+
+	// 2. ... and the connection can be used again after the release.
+	go func() {
+		_, _ = server.Read(make([]byte, 4))
+	}()
+	_, err = client.Write([]byte("free"))
+	s.Equal(nil, err)
+
+	// 3. The context without cancellation is not watched.
+	watchContext(context.Background(), client)()
+}
+
+func (s contextSuite) TestContextValues() {
+	extractor := ContextValues(map[string]interface{}{
+		"b": contextKey("b"),
+		"a": contextKey("a"),
+		"c": contextKey("missing"),
+	})
+
+	ctx := context.WithValue(context.Background(), contextKey("a"), 1)
+	ctx = context.WithValue(ctx, contextKey("b"), "two")
+
+	s.Equal([]interface{}{"a", 1, "b", "two"}, extractor(ctx))
+	s.Equal([]interface{}{}, extractor(context.Background()))
+
+	g := New(testInit)
+	s.Equal(0, len(g.contextFields(ctx)))
+}
+
+func (s contextSuite) TestChainContextExtractors() {
+	ctx := context.WithValue(context.Background(), contextKey("a"), 1)
+
+	extractor := ChainContextExtractors(
+		ContextValues(map[string]interface{}{"a": contextKey("a")}),
+		nil,
+		func(context.Context) []interface{} { return []interface{}{"static", "value"} },
+	)
+	s.Equal([]interface{}{"a", 1, "static", "value"}, extractor(ctx))
+	s.Equal(0, len(ChainContextExtractors()(ctx)))
+}
+
+func TestContextSuite(t *testing.T) {
+	suite.Run(t, new(contextSuite))
+}
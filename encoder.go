@@ -0,0 +1,142 @@
+package graylogger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// encoderErrorField is the name of the field, which is added to the log line of a failed Encoder.
+const encoderErrorField = "encoder_error"
+
+// Encoder writes the log lines of the logger functions, so the layout of stdOut can be customized.
+// The line is written by one Encode call, the trailing new line is optional.
+// The encoders are called concurrently, so they must be safe for concurrent use.
+// For example:
+//  type plainEncoder struct{}
+//
+//  func (plainEncoder) Encode(w io.Writer, r graylogger.Record) error {
+//      _, err := fmt.Fprintln(w, r.Level, r.Fields)
+//      return err
+//  }
+type Encoder interface {
+	Encode(w io.Writer, r Record) error
+}
+
+// Record is a log line of the logger functions, which is written by an Encoder.
+//  - Caller -> the tracking information of the function, which has called the logger function
+//  - Env -> Init.LogEnv
+//  - Provider -> Init.GraylogProvider
+//  - Fields -> the key : value pairs of the log call, followed by the fields of the context and the child logger
+type Record struct {
+	Time     time.Time
+	Level    LogLevel
+	Caller   TrackInfo
+	Env      string
+	Provider string
+	Fields   []Field
+}
+
+// Field is a key : value pair of a Record.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// TextEncoder writes the human-readable log lines, it is the default Encoder.
+// Its options are set by Init.LogColor, Init.LogMicroseconds and Init.LogUTC.
+// For example:
+//  [INFO] 2020/01/21 12:53:09 [file: example.go line: 39 function: main.main] [user :: 42]
+type TextEncoder struct {
+	Color        bool // The level and the tracking information are colored.
+	Microseconds bool // The time is written with microsecond precision.
+	UTC          bool // The date and time are written in UTC instead of the local time zone.
+}
+
+// Encode writes the record as a human-readable log line.
+func (e TextEncoder) Encode(w io.Writer, r Record) error {
+	init := Init{LogColor: e.Color}
+
+	layout := "2006/01/02 15:04:05"
+	if e.Microseconds {
+		layout += ".000000"
+	}
+
+	t := r.Time
+	if e.UTC {
+		t = t.UTC()
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s %s [%s]\n",
+		init.colorOut(levelColor(logLevelToInt(r.Level)), "["+strings.ToUpper(string(r.Level))+"] "),
+		t.Format(layout),
+		init.colorOut(colorGray, fmt.Sprintf("[file: %s line: %s function: %s]",
+			r.Caller.File,
+			r.Caller.Line,
+			r.Caller.Function)),
+		prettifyKeyVal(pairsToSlice(r.pairs())))
+	return err
+}
+
+// encoder returns with the Encoder of the log lines:
+// Init.LogEncoder if it is set, otherwise the built-in Encoder of Init.LogFormat.
+func (i Init) encoder() Encoder {
+	if i.LogEncoder != nil {
+		return i.LogEncoder
+	}
+
+	switch i.LogFormat {
+	case FormatJSON:
+		return JSONEncoder{}
+	case FormatLogfmt:
+		return LogfmtEncoder{Color: i.LogColor, UTC: i.LogUTC}
+	default:
+		return i.textEncoder()
+	}
+}
+
+// textEncoder returns with the TextEncoder configured by the Init.
+func (i Init) textEncoder() TextEncoder {
+	return TextEncoder{Color: i.LogColor, Microseconds: i.LogMicroseconds, UTC: i.LogUTC}
+}
+
+// newRecord creates the Record of a log call with given tracking information of the caller,
+// where pairs are the key : value pairs of the log call followed by the fields of the context and the child logger.
+func (g *GrayLogger) newRecord(level int, tr TrackInfo, pairs []keyValue) Record {
+	fields := make([]Field, 0, len(pairs))
+	for _, kv := range pairs {
+		fields = append(fields, Field{Key: kv.key, Value: kv.value})
+	}
+
+	return Record{
+		Time:     time.Now(),
+		Level:    LogLevel(logLevelToString(level)),
+		Caller:   tr,
+		Env:      g.initData.LogEnv,
+		Provider: g.initData.GraylogProvider,
+		Fields:   fields,
+	}
+}
+
+// encode writes the record by the Encoder of the logger, and returns with the log line without the trailing new line.
+// If the Encoder fails, the line is written by the TextEncoder with the error of the Encoder.
+func (g *GrayLogger) encode(r Record) string {
+	var b bytes.Buffer
+	if err := g.encoder.Encode(&b, r); err != nil {
+		b.Reset()
+		r.Fields = append(r.Fields, Field{Key: encoderErrorField, Value: err})
+		_ = g.textEncoder.Encode(&b, r)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// pairs returns with the fields of the record as key : value pairs.
+func (r Record) pairs() []keyValue {
+	pairs := make([]keyValue, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		pairs = append(pairs, keyValue{key: f.Key, value: f.Value})
+	}
+	return pairs
+}
@@ -0,0 +1,137 @@
+package graylogger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type encoderSuite struct {
+	suite.Suite
+}
+
+func (s encoderSuite) TestCustomEncoder() {
+	encoder := &recordingEncoder{}
+	init := testInit
+	init.GraylogProvider = "TestService"
+	init.GraylogContextExtractor = ContextValues(map[string]interface{}{"request_id": contextKey("request_id")})
+	init.LogEncoder = encoder
+
+	g := New(init).With("component", "db")
+	g.CaptureOutput(testOutputFileName)
+	g.WarningContext(context.WithValue(context.Background(), contextKey("request_id"), "r-1"), "user", 42)
+	g.Debug("no", "new line")
+	g.SaveOutput()
+
+	s.Equal("warning|user=42,request_id=r-1,component=db\ndebug|no=new line,component=db\n", g.GetOutput())
+
+	r := encoder.records()[0]
+	s.Equal(LevelWarning, r.Level)
+	s.Equal("test", r.Env)
+	s.Equal("TestService", r.Provider)
+	s.Equal("encoder_test.go", r.Caller.File)
+	s.Equal("graylogger.encoderSuite.TestCustomEncoder", r.Caller.Function)
+	s.Equal([]Field{{"user", 42}, {"request_id", "r-1"}, {"component", "db"}}, r.Fields)
+	s.Equal(true, time.Since(r.Time) < time.Minute)
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s encoderSuite) TestEncoderError() {
+	init := testInit
+	init.LogEncoder = &recordingEncoder{err: errors.New("broken layout")}
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42)
+	g.SaveOutput()
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "[INFO] "))
+	s.Equal(true, strings.HasSuffix(g.GetOutput(), "[user :: 42 :: encoder_error :: broken layout]\n"), g.GetOutput())
+	s.Equal(false, strings.Contains(g.GetOutput(), "partial"))
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s encoderSuite) TestTextEncoder() {
+	r := Record{
+		Time:   time.Date(2020, 1, 27, 13, 16, 54, 718421000, time.FixedZone("CET", 3600)),
+		Level:  LevelError,
+		Caller: TrackInfo{File: "example.go", Line: "21", Function: "main.main"},
+		Fields: []Field{{"order", "A-1"}, {"amount", 12}},
+	}
+
+	var b bytes.Buffer
+	s.Equal(nil, TextEncoder{}.Encode(&b, r))
+	s.Equal(fmt.Sprintf("%s2020/01/27 13:16:54 %s [order :: A-1 :: amount :: 12]\n",
+		testInit.colorOut(colorRed, "[ERROR] "),
+		testInit.colorOut(colorGray, "[file: example.go line: 21 function: main.main]")), b.String())
+
+	b.Reset()
+	s.Equal(nil, TextEncoder{Microseconds: true, UTC: true}.Encode(&b, r))
+	s.Equal(true, strings.Contains(b.String(), "2020/01/27 12:16:54.718421 "), b.String())
+
+	colored := Init{LogColor: true}
+	b.Reset()
+	s.Equal(nil, TextEncoder{Color: true}.Encode(&b, r))
+	s.Equal(true, strings.HasPrefix(b.String(), colored.colorOut(colorRed, "[ERROR] ")), b.String())
+}
+
+func (s encoderSuite) TestEncoder() {
+	custom := &recordingEncoder{}
+	s.Equal(TextEncoder{Color: true}, Init{LogColor: true}.encoder())
+	s.Equal(custom, Init{LogFormat: FormatJSON, LogEncoder: custom}.encoder())
+	s.Equal(custom, New(Init{LogEncoder: custom}).With("component", "db").encoder)
+
+	g := New(testInit)
+	s.Equal("", g.functions.Info.Prefix())
+	s.Equal(0, g.functions.Info.Flags())
+}
+
+// recordingEncoder records the encoded records, and writes them as level|key=value,... lines.
+// If err is set, it writes a partial line and fails.
+type recordingEncoder struct {
+	mu      sync.Mutex
+	err     error
+	encoded []Record
+}
+
+func (e *recordingEncoder) Encode(w io.Writer, r Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		_, _ = io.WriteString(w, "partial")
+		return e.err
+	}
+
+	e.encoded = append(e.encoded, r)
+
+	fields := make([]string, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		fields = append(fields, fmt.Sprintf("%s=%v", f.Key, f.Value))
+	}
+	_, err := fmt.Fprintf(w, "%s|%s", r.Level, strings.Join(fields, ","))
+	return err
+}
+
+func (e *recordingEncoder) records() []Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Record(nil), e.encoded...)
+}
+
+func TestEncoderSuite(t *testing.T) {
+	suite.Run(t, new(encoderSuite))
+}
@@ -0,0 +1,195 @@
+package graylogger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// graylogEndpointCooldown declares the default time an unhealthy Graylog endpoint is skipped.
+const graylogEndpointCooldown = 10 * time.Second
+
+// errNoEndpoint is the error of the GELF messages, which have no Graylog endpoint to be sent to.
+var errNoEndpoint = errors.New("no Graylog endpoint given")
+
+// Endpoint is the address of a Graylog input.
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+// EndpointStrategy declares how the Graylog endpoint of a GELF message is chosen among the healthy endpoints.
+type EndpointStrategy string
+
+const (
+	// EndpointFailover sends the GELF messages to the first healthy endpoint:
+	// GraylogHost:GraylogPort is the primary, Init.GraylogEndpoints are the secondaries in order.
+	EndpointFailover EndpointStrategy = "failover"
+
+	// EndpointRoundRobin sends the GELF messages to the healthy endpoints one after the other.
+	EndpointRoundRobin EndpointStrategy = "round_robin"
+
+	// EndpointRandom sends every GELF message to a randomly chosen healthy endpoint.
+	EndpointRandom EndpointStrategy = "random"
+)
+
+// EndpointStatus describes the health of a Graylog endpoint.
+// An endpoint is unhealthy after its connect or GELF write has failed, until the next successful one.
+// It is skipped until DownUntil, then it is tried again.
+type EndpointStatus struct {
+	Endpoint
+	Healthy   bool
+	DownUntil time.Time
+	LastError error
+}
+
+// endpoint holds the connection and the health of a Graylog endpoint.
+type endpoint struct {
+	Endpoint
+	sender    gelfSender
+	down      bool
+	downUntil time.Time
+	lastErr   error
+}
+
+// String returns with the host:port address of the endpoint.
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, fmt.Sprint(e.Port))
+}
+
+// endpoints returns with the Graylog endpoints of the Init:
+// GraylogHost:GraylogPort if it is set, followed by GraylogEndpoints.
+func (i Init) endpoints() []Endpoint {
+	var endpoints []Endpoint
+	if i.GraylogHost != "" && i.GraylogPort != 0 {
+		endpoints = append(endpoints, Endpoint{Host: i.GraylogHost, Port: i.GraylogPort})
+	}
+	return append(endpoints, i.GraylogEndpoints...)
+}
+
+// initEndpoints sets the defaults of the endpoint selection, and creates the endpoints of the connection.
+func (g *GrayLogger) initEndpoints() {
+	if g.initData.GraylogEndpointStrategy == "" {
+		g.initData.GraylogEndpointStrategy = EndpointFailover
+	}
+
+	if g.initData.GraylogEndpointCooldown == 0 {
+		g.initData.GraylogEndpointCooldown = graylogEndpointCooldown
+	}
+
+	if err := g.initData.GraylogEndpointStrategy.validateEndpointStrategy(); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	for _, e := range g.initData.endpoints() {
+		if err := e.validateEndpoint(); err != nil && g.isSetGraylogObligatoryFields() {
+			g.Fatal(err)
+		}
+		g.conn.endpoints = append(g.conn.endpoints, &endpoint{Endpoint: e})
+	}
+}
+
+// Endpoints returns with the health of the Graylog endpoints in the order of the Init.
+// It waits for the GELF write in progress.
+func (g *GrayLogger) Endpoints() []EndpointStatus {
+	g.conn.Lock()
+	defer g.conn.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(g.conn.endpoints))
+	for _, e := range g.conn.endpoints {
+		statuses = append(statuses, EndpointStatus{
+			Endpoint:  e.Endpoint,
+			Healthy:   !e.down,
+			DownUntil: e.downUntil,
+			LastError: e.lastErr,
+		})
+	}
+	return statuses
+}
+
+// available checks that the endpoint may be chosen or not: it is healthy, or its cooldown is elapsed.
+func (e *endpoint) available(now time.Time) bool {
+	return !e.down || !now.Before(e.downUntil)
+}
+
+// candidates returns with the endpoints in the order they are tried for the next GELF message.
+// The endpoints in cooldown are skipped, unless all of them are in cooldown: then all of them are tried.
+// With EndpointRoundRobin, the next call starts with the next endpoint, if advance is set.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) candidates(advance bool) []*endpoint {
+	n := len(g.conn.endpoints)
+	if n == 0 {
+		return nil
+	}
+
+	start := 0
+	switch g.initData.GraylogEndpointStrategy {
+	case EndpointRoundRobin:
+		start = g.conn.next % n
+		if advance {
+			g.conn.next = (start + 1) % n
+		}
+	case EndpointRandom:
+		randomSource.Lock()
+		start = randomSource.Intn(n)
+		randomSource.Unlock()
+	}
+
+	now := time.Now()
+	var available, all []*endpoint
+	for i := 0; i < n; i++ {
+		e := g.conn.endpoints[(start+i)%n]
+		all = append(all, e)
+		if e.available(now) {
+			available = append(available, e)
+		}
+	}
+
+	if len(available) == 0 {
+		return all
+	}
+	return available
+}
+
+// markUp marks the endpoint healthy, and logs it if it has been unhealthy.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) markUp(e *endpoint) {
+	wasDown := e.down
+	e.down = false
+	e.downUntil = time.Time{}
+
+	if wasDown && len(g.conn.endpoints) > 1 {
+		g.functions.Info.Println(g.formatLogLine(levelInfoNum, "Graylog endpoint", e.Endpoint, "state", "up"))
+	}
+}
+
+// markDown marks the endpoint unhealthy for Init.GraylogEndpointCooldown, and logs it if it has been healthy.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) markDown(e *endpoint, err error) {
+	wasDown := e.down
+	e.down = true
+	e.downUntil = time.Now().Add(g.initData.GraylogEndpointCooldown)
+	e.lastErr = err
+
+	if !wasDown && len(g.conn.endpoints) > 1 {
+		g.functions.Warning.Println(g.formatLogLine(levelWarningNum, "Graylog endpoint", e.Endpoint, "state", "down", "error", err))
+	}
+}
+
+// validateEndpointStrategy checks that given endpoint strategy is valid or not.
+func (s EndpointStrategy) validateEndpointStrategy() error {
+	switch s {
+	case EndpointFailover, EndpointRoundRobin, EndpointRandom:
+		return nil
+	}
+	return fmt.Errorf("invalid endpoint strategy given: %s", s)
+}
+
+// validateEndpoint checks that given Graylog endpoint is valid or not.
+func (e Endpoint) validateEndpoint() error {
+	if e.Host == "" || e.Port <= 0 || e.Port > 65535 {
+		return fmt.Errorf("invalid Graylog endpoint given: %s", e)
+	}
+	return nil
+}
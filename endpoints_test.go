@@ -0,0 +1,240 @@
+package graylogger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type endpointsSuite struct {
+	suite.Suite
+}
+
+func (s endpointsSuite) TestFailover() {
+	secondary := newTCPServer(graylogSuite{s.Suite})
+	defer secondary.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = retrySuite{s.Suite}.freePort()
+	init.GraylogEndpoints = []Endpoint{{Host: "127.0.0.1", Port: secondary.port()}}
+	init.GraylogEndpointCooldown = 100 * time.Millisecond
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	primary := Endpoint{Host: init.GraylogHost, Port: init.GraylogPort}
+
+	// 1. The primary is down, so the messages are sent to the secondary ...
+	s.Equal(nil, g.SendGELF(levelInfoNum, "test", "secondary"))
+	s.Equal("test :: secondary", secondary.message()["short_message"])
+
+	statuses := g.Endpoints()
+	s.Equal(2, len(statuses))
+	s.Equal(primary, statuses[0].Endpoint)
+	s.Equal(false, statuses[0].Healthy)
+	s.Equal(true, isDeliveryOp(statuses[0].LastError, DeliveryConnect))
+	s.Equal(true, statuses[1].Healthy)
+	s.Equal(nil, statuses[1].LastError)
+
+	// 2. ... until the primary is back and its cooldown is elapsed.
+	l, err := net.Listen("tcp", primary.String())
+	s.Require().Equal(nil, err)
+	server := newListenerServer(graylogSuite{s.Suite}, l)
+	defer server.close()
+
+	time.Sleep(init.GraylogEndpointCooldown)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "test", "primary"))
+	s.Equal("test :: primary", server.message()["short_message"])
+	s.Equal(true, g.Endpoints()[0].Healthy)
+
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	output, err := ioutil.ReadFile(testOutputFileName)
+	s.Equal(nil, err)
+	s.Equal(1, strings.Count(string(output), fmt.Sprintf("[Graylog endpoint :: %s :: state :: down :: error :: ", primary)))
+	s.Equal(1, strings.Count(string(output), fmt.Sprintf("[Graylog endpoint :: %s :: state :: up]", primary)))
+	s.Equal(0, strings.Count(string(output), "could not connect to Graylog host"))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s endpointsSuite) TestAllDown() {
+	init := testInit
+	init.GraylogEndpoints = []Endpoint{
+		{Host: "127.0.0.1", Port: retrySuite{s.Suite}.freePort()},
+		{Host: "127.0.0.1", Port: retrySuite{s.Suite}.freePort()},
+	}
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	s.Equal(true, isDeliveryOp(g.SendGELF(levelInfoNum, "test", "lost"), DeliveryConnect))
+	for _, status := range g.Endpoints() {
+		s.Equal(false, status.Healthy)
+		s.Equal(true, status.DownUntil.After(time.Now()))
+	}
+
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	output, err := ioutil.ReadFile(testOutputFileName)
+	s.Equal(nil, err)
+	s.Equal(2, strings.Count(string(output), "[Graylog endpoint :: "))
+	s.Equal(1, strings.Count(string(output), "could not connect to Graylog host"))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s endpointsSuite) TestHealthyOnCallerErrors() {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer slow.Close()
+
+	rejecting := newHTTPServer(http.StatusBadRequest)
+	defer rejecting.Close()
+
+	secondary := newHTTPServer(http.StatusAccepted)
+	defer secondary.Close()
+
+	for _, primary := range []*httptest.Server{slow, rejecting.Server} {
+		init := (&httpServer{Server: primary}).init(httpSuite{s.Suite}, TransportHTTP)
+		init.GraylogStructured = true
+		init.GraylogEndpoints = []Endpoint{s.endpoint(secondary.Server)}
+
+		g := New(init)
+		g.CaptureOutput(testOutputFileName)
+
+		// 1. The deadline of the log call and ...
+		// 2. ... the rejected message are not the failures of the endpoint.
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := g.SendGELFContext(ctx, levelInfoNum, "test", "healthy")
+		cancel()
+		s.NotEqual(nil, err)
+
+		s.Equal(true, g.Endpoints()[0].Healthy, primary.URL)
+		s.Equal(nil, g.Endpoints()[0].LastError)
+		s.Equal(0, len(secondary.requests()))
+
+		g.SaveOutput()
+		s.Equal(false, strings.Contains(g.GetOutput(), "state :: down"), g.GetOutput())
+		s.Equal(nil, g.Close())
+	}
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s endpointsSuite) TestRoundRobin() {
+	servers, g := s.newLogger(EndpointRoundRobin, 3)
+	defer g.Close()
+
+	for i := 0; i < 6; i++ {
+		s.Equal(nil, g.SendGELF(levelInfoNum, "test", i))
+	}
+
+	for i, server := range servers {
+		s.Equal(fmt.Sprintf("test :: %d", i), server.message()["short_message"])
+		s.Equal(fmt.Sprintf("test :: %d", i+3), server.message()["short_message"])
+		server.close()
+	}
+}
+
+func (s endpointsSuite) TestRandom() {
+	servers, g := s.newLogger(EndpointRandom, 2)
+	defer g.Close()
+
+	for i := 0; i < 40; i++ {
+		s.Equal(nil, g.SendGELF(levelInfoNum, "test", i))
+	}
+
+	s.Eventually(func() bool {
+		return len(servers[0].messages)+len(servers[1].messages) == 40
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, server := range servers {
+		s.Equal(true, len(server.messages) > 0)
+		server.close()
+	}
+}
+
+func (s endpointsSuite) TestInitEndpoints() {
+	g := New(Init{GraylogProtocol: TransportTCP, LogLevel: LevelDebug})
+	s.Equal(EndpointFailover, g.GetInit().GraylogEndpointStrategy)
+	s.Equal(graylogEndpointCooldown, g.GetInit().GraylogEndpointCooldown)
+	s.Equal(0, len(g.Endpoints()))
+	s.Equal(false, g.isSetGraylogObligatoryFields())
+
+	g = New(Init{
+		GraylogHost:      "primary",
+		GraylogPort:      12201,
+		GraylogEndpoints: []Endpoint{{Host: "secondary", Port: 12202}},
+		GraylogProvider:  "TestService",
+		GraylogProtocol:  TransportTCP,
+		LogLevel:         LevelDebug,
+	})
+	s.Equal(true, g.isSetGraylogObligatoryFields())
+	s.Equal([]EndpointStatus{
+		{Endpoint: Endpoint{Host: "primary", Port: 12201}, Healthy: true},
+		{Endpoint: Endpoint{Host: "secondary", Port: 12202}, Healthy: true},
+	}, g.Endpoints())
+	s.Equal("secondary:12202", g.Endpoints()[1].String())
+
+	init := Init{GraylogProvider: "TestService", GraylogProtocol: TransportTCP, LogLevel: LevelDebug}
+	init.GraylogEndpoints = []Endpoint{{Host: "secondary", Port: 12202}}
+	g = New(init)
+	s.Equal(true, g.isSetGraylogObligatoryFields())
+
+	s.Equal(nil, EndpointRoundRobin.validateEndpointStrategy())
+	s.Equal("invalid endpoint strategy given: sticky", fmt.Sprint(EndpointStrategy("sticky").validateEndpointStrategy()))
+	s.Equal(nil, Endpoint{Host: "::1", Port: 12201}.validateEndpoint())
+	s.Equal("invalid Graylog endpoint given: :12201", fmt.Sprint(Endpoint{Port: 12201}.validateEndpoint()))
+	s.Equal("invalid Graylog endpoint given: graylog:0", fmt.Sprint(Endpoint{Host: "graylog"}.validateEndpoint()))
+}
+
+func (s endpointsSuite) newLogger(strategy EndpointStrategy, n int) ([]*tcpServer, *GrayLogger) {
+	init := testInit
+	init.GraylogEndpointStrategy = strategy
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	var servers []*tcpServer
+	for i := 0; i < n; i++ {
+		server := newTCPServer(graylogSuite{s.Suite})
+		servers = append(servers, server)
+		init.GraylogEndpoints = append(init.GraylogEndpoints, Endpoint{Host: "127.0.0.1", Port: server.port()})
+	}
+
+	return servers, New(init)
+}
+
+func (s endpointsSuite) endpoint(server *httptest.Server) Endpoint {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	s.Require().Equal(nil, err)
+
+	e := Endpoint{Host: host}
+	e.Port, _ = strconv.Atoi(port)
+	return e
+}
+
+func TestEndpointsSuite(t *testing.T) {
+	suite.Run(t, new(endpointsSuite))
+}
@@ -0,0 +1,131 @@
+package graylogger
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+)
+
+// DeliveryOp declares the step of the GELF delivery, which has failed.
+type DeliveryOp string
+
+const (
+	// DeliveryConnect means the Graylog connection could not be opened.
+	DeliveryConnect DeliveryOp = "connect"
+
+	// DeliveryWrite means the GELF message could not be written into the Graylog connection.
+	DeliveryWrite DeliveryOp = "write"
+
+	// DeliveryEncode means the GELF message could not be encoded, compressed or split into GELF chunks.
+	DeliveryEncode DeliveryOp = "encode"
+
+	// DeliveryDrop means the GELF message was dropped by the asynchronous queue or by the limits of the spool.
+	DeliveryDrop DeliveryOp = "drop"
+
+	// DeliverySpool means the GELF message could not be stored into the spool.
+	DeliverySpool DeliveryOp = "spool"
+)
+
+// errQueueFull is the error of the GELF messages dropped by OverflowDropNewest and OverflowDropOldest.
+var errQueueFull = errors.New("asynchronous GELF queue is full")
+
+// DeliveryError describes why a GELF message could not be delivered into Graylog instance.
+// The underlying error is available by errors.Is and errors.As.
+type DeliveryError struct {
+	Op  DeliveryOp
+	Err error
+}
+
+// Error returns with the failed step and the underlying error.
+// For example:
+//  GELF write failed: write tcp 127.0.0.1:52712->127.0.0.1:12201: write: broken pipe
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("GELF %s failed: %s", e.Op, e.Err)
+}
+
+// Unwrap returns with the underlying error.
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// deliveryError wraps the error into a DeliveryError of the given step,
+// unless it is a DeliveryError already.
+func deliveryError(op DeliveryOp, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var de *DeliveryError
+	if errors.As(err, &de) {
+		return err
+	}
+	return &DeliveryError{Op: op, Err: err}
+}
+
+// isDeliveryOp checks that the error is a DeliveryError of the given step.
+func isDeliveryOp(err error, op DeliveryOp) bool {
+	var de *DeliveryError
+	return errors.As(err, &de) && de.Op == op
+}
+
+// onErrorFunction is the name of the function calling Init.GraylogOnError,
+// it is looked for in the call stack of the GELF messages logged by the callback.
+var onErrorFunction = runtime.FuncForPC(reflect.ValueOf(callOnError).Pointer()).Name()
+
+// reportError stores the error as the last delivery error, and passes it to Init.GraylogOnError
+// with the GELF message, which has been lost.
+func (g *GrayLogger) reportError(err error, m GELFMessage) {
+	g.conn.errMu.Lock()
+	g.conn.lastErr = err
+	g.conn.errMu.Unlock()
+
+	if g.initData.GraylogOnError != nil {
+		atomic.AddInt32(&g.conn.reporting, 1)
+		defer atomic.AddInt32(&g.conn.reporting, -1)
+
+		callOnError(g.initData.GraylogOnError, err, m)
+	}
+}
+
+// callOnError calls the Init.GraylogOnError callback.
+func callOnError(onError func(error, GELFMessage), err error, m GELFMessage) {
+	onError(err, m)
+}
+
+// inOnError checks that the caller runs inside Init.GraylogOnError.
+// The call stack is only inspected, while the callback is running somewhere.
+func (g *GrayLogger) inOnError() bool {
+	if atomic.LoadInt32(&g.conn.reporting) == 0 {
+		return false
+	}
+
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	for n == len(pcs) {
+		pcs = make([]uintptr, 2*len(pcs))
+		n = runtime.Callers(2, pcs)
+	}
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.Function == onErrorFunction {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// LastError returns with the error of the last GELF message, which could not be delivered,
+// or nil if every message has been delivered so far.
+// The error is shared by the logger and its child loggers.
+func (g *GrayLogger) LastError() error {
+	g.conn.errMu.Lock()
+	defer g.conn.errMu.Unlock()
+
+	return g.conn.lastErr
+}
@@ -0,0 +1,214 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type errorsSuite struct {
+	suite.Suite
+}
+
+func (s errorsSuite) TestConnectError() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	s.Require().Equal(nil, l.Close())
+
+	reported := &reportedErrors{}
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = port
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogOnError = reported.add
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	s.Equal(nil, g.LastError())
+
+	err = g.SendGELF(levelInfoNum, "key", "value", "other", 1)
+	g.SaveOutput()
+
+	var de *DeliveryError
+	s.Require().Equal(true, errors.As(err, &de))
+	s.Equal(DeliveryConnect, de.Op)
+	s.Equal(true, strings.HasPrefix(err.Error(), "GELF connect failed: "))
+
+	s.Equal([]string{"key :: value", "other :: 1"}, reported.messages())
+	s.Equal(err, g.LastError())
+	s.Equal(err, g.With("child", true).LastError())
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s errorsSuite) TestWriteError() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	reported := &reportedErrors{}
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.Listener.Addr().(*net.TCPAddr).Port
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportHTTP
+	init.GraylogOnError = reported.add
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	err := g.SendGELF(levelInfoNum, "key", "value")
+	g.SaveOutput()
+
+	s.Equal(true, isDeliveryOp(err, DeliveryWrite))
+	s.Equal("GELF write failed: GELF HTTP input responded with status: 400 Bad Request", fmt.Sprint(err))
+	s.Equal([]string{"key :: value"}, reported.messages())
+	s.Equal(err, g.LastError())
+
+	s.Equal(nil, g.Close())
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s errorsSuite) TestEncodeError() {
+	server := newChunkServer(udpSuite{s.Suite})
+	defer server.close()
+
+	reported := &reportedErrors{}
+	init := server.init()
+	init.GraylogUDPChunkSize = 20
+	init.GraylogOnError = reported.add
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	err := g.SendGELF(levelInfoNum, "key", strings.Repeat("x", 2000))
+	g.SaveOutput()
+
+	s.Equal(true, isDeliveryOp(err, DeliveryEncode))
+	s.Equal(1, len(reported.messages()))
+	s.Equal(err, g.LastError())
+
+	s.Equal(nil, g.Close())
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s errorsSuite) TestNoError() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	reported := &reportedErrors{}
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogOnError = reported.add
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "key", "value"))
+	s.Equal(nil, g.SendGELF(levelDebugNum+1, "not", "logged"))
+	g.SaveOutput()
+
+	s.Equal("key :: value", server.message()["short_message"])
+	s.Equal(0, len(reported.messages()))
+	s.Equal(nil, g.LastError())
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s errorsSuite) TestOnErrorLogs() {
+	for _, async := range []bool{false, true} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+
+		var g *GrayLogger
+		reported := &reportedErrors{}
+		init := (&httpServer{Server: server}).init(httpSuite{s.Suite}, TransportHTTP)
+		init.GraylogAsync = async
+		init.GraylogQueueSize = 1
+		init.GraylogWorkers = 1
+		init.GraylogOverflow = OverflowBlock
+		init.GraylogOnError = func(err error, m GELFMessage) {
+			reported.add(err, m)
+			g.Error("lost", m.ShortMessage)
+		}
+
+		g = New(init)
+		g.CaptureOutput(testOutputFileName)
+		for i := 0; i < 3; i++ {
+			g.Info("key", i)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.Equal(nil, g.Flush(ctx), async)
+		cancel()
+		g.SaveOutput()
+
+		s.Equal([]string{"key :: 0", "key :: 1", "key :: 2"}, reported.messages(), async)
+		s.Equal(true, isHTTPStatusError(g.LastError()), async)
+
+		out, err := ioutil.ReadFile(testOutputFileName)
+		s.Equal(nil, err)
+		s.Contains(string(out), "[lost :: key :: 2]", async)
+
+		s.Equal(nil, g.Close())
+		server.Close()
+		err = os.Remove(testOutputFileName)
+		s.Equal(nil, err)
+	}
+}
+
+func (s errorsSuite) TestDeliveryError() {
+	cause := errors.New("broken pipe")
+	err := deliveryError(DeliveryWrite, cause)
+
+	s.Equal("GELF write failed: broken pipe", err.Error())
+	s.Equal(true, errors.Is(err, cause))
+	s.Equal(cause, errors.Unwrap(err))
+
+	s.Equal(err, deliveryError(DeliveryConnect, err))
+	s.Equal(nil, deliveryError(DeliveryWrite, nil))
+	s.Equal(false, isDeliveryOp(cause, DeliveryWrite))
+}
+
+// reportedErrors collects the short messages passed to Init.GraylogOnError.
+type reportedErrors struct {
+	mu   sync.Mutex
+	lost []string
+}
+
+func (r *reportedErrors) add(_ error, m GELFMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lost = append(r.lost, m.ShortMessage)
+}
+
+func (r *reportedErrors) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lost...)
+}
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, new(errorsSuite))
+}
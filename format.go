@@ -0,0 +1,209 @@
+package graylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// timestampLayout is the layout of the timestamp in the JSON and logfmt log lines,
+// with the microsecond precision of the GELF timestamps.
+const timestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// logfmtFields holds the names of the logfmt fields, which can not be overwritten by the key : value pairs.
+var logfmtFields = map[string]bool{"level": true, "ts": true, "caller": true, "function": true, "env": true, "provider": true}
+
+// Format declares the format of the log lines written to stdOut (FormatText, FormatJSON or FormatLogfmt).
+type Format string
+
+const (
+	// FormatText writes human-readable log lines.
+	// For example:
+	//  [INFO] 2020/01/21 12:53:09 [file: example.go line: 39 function: main.main] [user :: 42]
+	FormatText Format = "text"
+
+	// FormatJSON writes one JSON object per log line, whose fields are named like the GELF fields.
+	// For example:
+	//  {"host":"example-service","level":6,"log_env":"prod","log_level":"info","short_message":"user :: 42","timestamp":1579607589.123456,"track_file":"example.go","track_function":"main.main","track_line":"39","user":"42"}
+	FormatJSON Format = "json"
+
+	// FormatLogfmt writes the log lines as logfmt key=value pairs, the values are quoted if needed.
+	// For example:
+	//  level=info ts=2020-01-21T12:53:09.123456+01:00 caller=example.go:39 function=main.main env=prod user=42
+	FormatLogfmt Format = "logfmt"
+)
+
+// validateFormat checks that given log format is valid or not.
+func (f Format) validateFormat() error {
+	switch f {
+	case FormatText, FormatJSON, FormatLogfmt:
+		return nil
+	}
+	return fmt.Errorf("invalid log format given: %s", f)
+}
+
+// JSONEncoder writes one JSON object per log line, whose fields are named like the fields of the structured GELF message:
+//  - host, level, short_message and timestamp, like the GELF fields
+//    (the timestamp is the Unix time in seconds with microsecond precision, e.g. 1579607589.123456)
+//  - the additional fields without the leading underscore, e.g. log_env, track_file and the key : value pairs
+// It is the Encoder of FormatJSON.
+type JSONEncoder struct{}
+
+// Encode writes the record as a JSON object.
+func (e JSONEncoder) Encode(w io.Writer, r Record) error {
+	level := logLevelToInt(r.Level)
+	pairs := r.pairs()
+	extra := structuredExtraFields(r.Env, level, r.Caller, pairs)
+
+	line := map[string]interface{}{
+		"host":          r.Provider,
+		"level":         level,
+		"short_message": cleanString(prettifyKeyVal(pairsToSlice(pairs))),
+		"timestamp":     gelfTimestamp(r.Time),
+	}
+
+	// The additional fields colliding with the GELF fields are prefixed the same way as the reserved ones.
+	for name := range line {
+		if value, exists := extra[name]; exists {
+			delete(extra, name)
+			extra[uniqueFieldName(extra, "field_"+name)] = value
+		}
+	}
+
+	for name, value := range extra {
+		line[name] = value
+	}
+
+	js, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
+
+// LogfmtEncoder writes the log lines as logfmt key=value pairs, where the key : value pairs follow the fields of the caller.
+// The level is colored, only if Color is set.
+// It is the Encoder of FormatLogfmt, its options are set by Init.LogColor and Init.LogUTC.
+// For example:
+//  level=info ts=2020-01-21T12:53:09.123456+01:00 caller=example.go:39 function=main.main env=prod user=42 note="two words"
+type LogfmtEncoder struct {
+	Color bool // The level is colored.
+	UTC   bool // The timestamp is written in UTC instead of the local time zone.
+}
+
+// Encode writes the record as a logfmt line.
+func (e LogfmtEncoder) Encode(w io.Writer, r Record) error {
+	var b strings.Builder
+
+	init := Init{LogColor: true}
+	levelField := "level=" + string(r.Level)
+	if e.Color {
+		levelField = init.colorOut(levelColor(logLevelToInt(r.Level)), levelField)
+	}
+	b.WriteString(levelField)
+
+	writeLogfmtPair(&b, "ts", lineTime(r.Time, e.UTC).Format(timestampLayout))
+	writeLogfmtPair(&b, "caller", r.Caller.File+":"+r.Caller.Line)
+	writeLogfmtPair(&b, "function", r.Caller.Function)
+	if r.Env != "" {
+		writeLogfmtPair(&b, "env", r.Env)
+	}
+	if r.Provider != "" {
+		writeLogfmtPair(&b, "provider", r.Provider)
+	}
+
+	for _, f := range r.Fields {
+		key := logfmtKey(f.Key)
+		if logfmtFields[key] {
+			key = "field_" + key
+		}
+		writeLogfmtPair(&b, key, logfmtValue(f.Value))
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// lineTime returns with the time of a JSON or logfmt log line, in UTC if utc is set.
+func lineTime(t time.Time, utc bool) time.Time {
+	if utc {
+		return t.UTC()
+	}
+	return t
+}
+
+// levelColor returns with the color of the log lines of given log level.
+func levelColor(level int) string {
+	switch {
+	case level >= levelDebugNum:
+		return colorGreen
+	case level >= levelInfoNum:
+		return colorBlue
+	case level >= levelWarningNum:
+		return colorPurple
+	case level >= levelErrorNum:
+		return colorRed
+	default:
+		return colorYellow
+	}
+}
+
+// writeLogfmtPair writes a space separated key=value pair to the logfmt log line,
+// where the value is quoted if needed.
+func writeLogfmtPair(b *strings.Builder, key, value string) {
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(quoteLogfmt(value))
+}
+
+// logfmtKey converts a key into a valid logfmt key:
+// the spaces, the control characters, the equal signs and the quotes are replaced by underscores.
+// For example:
+//  "user name" -> user_name
+func logfmtKey(key string) string {
+	name := []rune(key)
+	for i, r := range name {
+		if r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 {
+		return "field"
+	}
+	return string(name)
+}
+
+// logfmtValue converts a value into a single line string the same way as the GELF additional fields,
+// where the JSON values (e.g. the structures, slices and maps) are compacted.
+// For example:
+//  map[string]int{"id": 42} -> {"id":42}
+func logfmtValue(value interface{}) string {
+	s := strings.TrimSpace(prettifyObject(value))
+
+	var compacted bytes.Buffer
+	if json.Valid([]byte(s)) && json.Compact(&compacted, []byte(s)) == nil {
+		return compacted.String()
+	}
+	return s
+}
+
+// quoteLogfmt quotes a logfmt value, if it is empty or contains spaces, control characters, equal signs or quotes.
+// The quoted value is escaped like a Go string literal, e.g. the new lines are written as \n.
+func quoteLogfmt(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+	}) < 0 {
+		return value
+	}
+	return strconv.Quote(value)
+}
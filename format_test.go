@@ -0,0 +1,203 @@
+package graylogger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type formatSuite struct {
+	suite.Suite
+}
+
+func (s formatSuite) TestJSONLines() {
+	init := testInit
+	init.LogFormat = FormatJSON
+	init.LogUTC = true
+	init.GraylogProvider = "TestService"
+	init.GraylogContextExtractor = ContextValues(map[string]interface{}{"request_id": contextKey("request_id")})
+
+	g := New(init).With("component", "db")
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42, "message", "multi\nline")
+	g.WarningContext(context.WithValue(context.Background(), contextKey("request_id"), "r-1"), "host", "h", "level", 1)
+	g.SaveOutput()
+
+	lines := strings.Split(strings.TrimSuffix(g.GetOutput(), "\n"), "\n")
+	s.Require().Equal(2, len(lines))
+
+	info := s.decode(lines[0])
+	s.Equal(float64(levelInfoNum), info["level"])
+	s.Equal("info", info["log_level"])
+	s.Equal("test", info["log_env"])
+	s.Equal("TestService", info["host"])
+	s.Equal("user :: 42 :: message :: multiline :: component :: db", info["short_message"])
+	s.Equal("42", info["user"])
+	s.Equal("multi\nline", info["message"])
+	s.Equal("db", info["component"])
+	s.Equal("format_test.go", info["track_file"])
+	s.Equal("graylogger.formatSuite.TestJSONLines", info["track_function"])
+
+	seconds, ok := info["timestamp"].(float64)
+	s.Require().Equal(true, ok, info["timestamp"])
+	s.Equal(true, time.Since(time.Unix(int64(seconds), 0)) < time.Minute)
+
+	warning := s.decode(lines[1])
+	s.Equal(float64(levelWarningNum), warning["level"])
+	s.Equal("warning", warning["log_level"])
+	s.Equal("TestService", warning["host"])
+	s.Equal("h", warning["field_host"])
+	s.Equal("1", warning["field_level"])
+	s.Equal("r-1", warning["request_id"])
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s formatSuite) TestSameFieldsAsGELF() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStructured = true
+	init.LogFormat = FormatJSON
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Error("user", map[string]int{"id": 42}, "status", "failed")
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	gelf := server.message()
+	line := s.decode(strings.TrimSpace(g.GetOutput()))
+	for name, value := range gelf {
+		if strings.HasPrefix(name, "_") {
+			s.Equal(value, line[strings.TrimPrefix(name, "_")], name)
+		}
+	}
+	s.Equal(gelf["host"], line["host"])
+	s.Equal(gelf["level"], line["level"])
+	s.Equal(gelf["short_message"], line["short_message"])
+
+	// The timestamps are numbers in both, taken a few microseconds apart.
+	s.InDelta(gelf["timestamp"], line["timestamp"], 1)
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s formatSuite) TestLogfmtLines() {
+	init := testInit
+	init.LogFormat = FormatLogfmt
+	init.LogUTC = true
+	init.GraylogProvider = "TestService"
+
+	g := New(init).With("component", "db")
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42, "note", "two words", "level", "high")
+	g.Error("payload", map[string]int{"id": 42}, "error", "line 1\nline \"2\"", "empty", "")
+	g.SaveOutput()
+
+	lines := strings.Split(strings.TrimSuffix(g.GetOutput(), "\n"), "\n")
+	s.Require().Equal(2, len(lines))
+
+	s.Equal(true, strings.HasPrefix(lines[0], "level=info ts="), lines[0])
+	s.Equal(true, strings.Contains(lines[0], "Z caller=format_test.go:"), lines[0])
+	s.Equal(true, strings.HasSuffix(lines[0], ` function=graylogger.formatSuite.TestLogfmtLines env=test provider=TestService user=42 note="two words" field_level=high component=db`), lines[0])
+
+	s.Equal(true, strings.HasPrefix(lines[1], "level=error ts="), lines[1])
+	s.Equal(true, strings.HasSuffix(lines[1], ` payload="{\"id\":42}" error="line 1\nline \"2\"" empty="" component=db`), lines[1])
+	s.Equal(false, strings.Contains(g.GetOutput(), "\x1b"))
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s formatSuite) TestLogfmtColor() {
+	init := testInit
+	init.LogFormat = FormatLogfmt
+	init.LogColor = true
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Warning("user", 42)
+	g.SaveOutput()
+
+	s.Equal(true, strings.HasPrefix(g.GetOutput(), init.colorOut(colorPurple, "level=warning")+" ts="), g.GetOutput())
+	s.Equal(true, strings.HasSuffix(g.GetOutput(), " env=test user=42\n"), g.GetOutput())
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s formatSuite) TestLogfmtQuoting() {
+	for value, quoted := range map[string]string{
+		"plain":           "plain",
+		"":                `""`,
+		"two words":       `"two words"`,
+		"a=b":             `"a=b"`,
+		`say "hi"`:        `"say \"hi\""`,
+		`C:\dir`:          `"C:\\dir"`,
+		"tab\there":       `"tab\there"`,
+		"bell\a":          `"bell\a"`,
+		"árvíztűrő":       "árvíztűrő",
+		"multi\nline":     `"multi\nline"`,
+		"{\"id\":42}":     `"{\"id\":42}"`,
+		"trailing space ": `"trailing space "`,
+	} {
+		s.Equal(quoted, quoteLogfmt(value), value)
+	}
+
+	s.Equal("user_name", logfmtKey("user name"))
+	s.Equal("a_b_c", logfmtKey("a=b\"c"))
+	s.Equal("field", logfmtKey(""))
+
+	s.Equal(`{"id":42}`, logfmtValue(map[string]int{"id": 42}))
+	s.Equal(`[1,2]`, logfmtValue([]int{1, 2}))
+	s.Equal("<nil>", logfmtValue(nil))
+	s.Equal("two words", logfmtValue(" two words "))
+}
+
+func (s formatSuite) TestTextFormat() {
+	g := New(testInit)
+	s.Equal(FormatText, g.GetInit().LogFormat)
+
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42)
+	g.SaveOutput()
+	s.Equal(true, strings.Contains(g.GetOutput(), "[INFO] "))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[user :: 42]"))
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s formatSuite) TestValidateFormat() {
+	s.Equal(nil, FormatText.validateFormat())
+	s.Equal(nil, FormatJSON.validateFormat())
+	s.Equal(nil, FormatLogfmt.validateFormat())
+	s.Equal("invalid log format given: yaml", fmt.Sprint(Format("yaml").validateFormat()))
+
+	s.Equal(JSONEncoder{}, Init{LogFormat: FormatJSON, LogUTC: true, LogColor: true}.encoder())
+	s.Equal(LogfmtEncoder{Color: true}, Init{LogFormat: FormatLogfmt, LogColor: true, LogMicroseconds: true}.encoder())
+}
+
+func (s formatSuite) decode(line string) map[string]interface{} {
+	obj := map[string]interface{}{}
+	s.Require().Equal(nil, json.Unmarshal([]byte(line), &obj), line)
+	return obj
+}
+
+func TestFormatSuite(t *testing.T) {
+	suite.Run(t, new(formatSuite))
+}
@@ -4,8 +4,6 @@ go 1.13
 
 require (
 	bou.ke/monkey v1.0.2
-	github.com/Devatoria/go-graylog v0.0.0-20171023211614-c16c35c9383b
-	github.com/Jeffail/gabs v1.4.0 // indirect
 	github.com/bclicn/color v0.0.0-20180711051946-108f2023dc84
 	github.com/stretchr/testify v1.4.0
 	github.com/tidwall/pretty v1.0.0
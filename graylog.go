@@ -1,9 +1,50 @@
 package graylogger
 
+import "context"
+
 // SendGELF sends GELF messages into Graylog instance.
 // If the Graylog host is unreachable, it writes an error message to stdOut.
-func (g *GrayLogger) SendGELF(level int, keysAndValues ...interface{}) {
-	if g.validateGraylogArguments(level) {
-		g.connect().send(level, keysAndValues)
+// It returns with the *DeliveryError of the first message, which could not be delivered.
+// With Init.GraylogAsync the messages are only queued, their errors are passed to Init.GraylogOnError.
+func (g *GrayLogger) SendGELF(level int, keysAndValues ...interface{}) error {
+	return g.sendGELFTracked(context.Background(), level, getTrackingInfo(2), keysAndValues, g.boundFields(context.Background()))
+}
+
+// SendGELFContext sends GELF messages into Graylog instance, until the context is done:
+// the cancellation and the deadline of the context are applied to the network delivery.
+// The fields pulled out of the context by Init.GraylogContextExtractor are added to the messages.
+// It returns with the error of the first message, which could not be delivered, like SendGELF.
+func (g *GrayLogger) SendGELFContext(ctx context.Context, level int, keysAndValues ...interface{}) error {
+	return g.sendGELFTracked(ctx, level, getTrackingInfo(2), keysAndValues, g.boundFields(ctx))
+}
+
+// Flush waits until all GELF messages of the asynchronous queue are sent,
+// or the context is done. Without Init.GraylogAsync it returns immediately.
+func (g *GrayLogger) Flush(ctx context.Context) error {
+	if g.queue == nil {
+		return nil
+	}
+	return g.queue.flush(ctx)
+}
+
+// Close sends the queued GELF messages and releases the Graylog connection held by the logger.
+// The replay of the spool is stopped, the spooled messages are kept on the disk.
+// The connection is re-opened by the next GELF message, if it is needed.
+func (g *GrayLogger) Close() error {
+	if g.queue != nil {
+		g.queue.stop()
+	}
+
+	var spoolErr error
+	if g.spool != nil {
+		spoolErr = g.spool.stop()
+	}
+
+	g.conn.Lock()
+	defer g.conn.Unlock()
+
+	if err := g.disconnect(); err != nil {
+		return err
 	}
+	return spoolErr
 }
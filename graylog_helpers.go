@@ -1,17 +1,23 @@
 package graylogger
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
-	"github.com/Devatoria/go-graylog"
 	"github.com/tidwall/pretty"
 )
 
+// redactedText replaces the credentials of the Init in the log messages.
+const redactedText = "[REDACTED]"
+
 // graylogTimeout declares the maximum amount of time
 // a dial will wait for a connection to complete.
 const graylogTimeout = 100 * time.Millisecond
@@ -27,77 +33,685 @@ type GraylogExtraFields struct {
 	Function string `json:"track_function"`
 }
 
+// GELFMessage represents a GELF message, which is sent into Graylog instance.
+// The Timestamp is sent with microsecond precision, and the Extra fields are prefixed by an underscore.
+type GELFMessage struct {
+	Version      string
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    time.Time
+	Level        uint
+	Extra        map[string]string
+}
+
+// gelfSender sends GELF messages through an established Graylog connection,
+// until the context is done.
+// It is implemented by *streamSender for TCP and TLS, by *udpSender for UDP and by *httpSender for HTTP(S).
+// Send is called without the lock of the connection, e.g. by several asynchronous workers, so it must be safe for concurrent use.
+type gelfSender interface {
+	Send(ctx context.Context, m GELFMessage) error
+	Close() error
+}
+
+// streamSender sends null byte delimited GELF messages through a TCP or TLS connection.
+// The messages are written one by one, because the deadline of the context is applied to the whole connection.
+type streamSender struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// truncatedField is the name of the additional field, which marks the truncated GELF messages.
+const truncatedField = "truncated"
+
+// reservedExtraFields holds the names of the GraylogExtraFields and the truncated marker,
+// which can not be overwritten by the key : value pairs.
+var reservedExtraFields = func() map[string]string {
+	reserved := createExtraFieldsMap(GraylogExtraFields{})
+	reserved[truncatedField] = ""
+	return reserved
+}()
+
+// connection holds the long-lived Graylog connection of a GrayLogger.
+// It is opened lazily by connect(), reused across log calls
+// and released by GrayLogger.Close().
+// Every Graylog endpoint has its own connection, next is the position of EndpointRoundRobin.
+// The lock guards the endpoints while they are chosen, connected and marked, the messages are sent without it.
+// The last delivery error is guarded by its own lock, so it can be read during a slow write.
+// The reporting counter holds the number of Init.GraylogOnError calls in progress.
+type connection struct {
+	sync.Mutex
+	endpoints []*endpoint
+	next      int
+
+	errMu     sync.Mutex
+	lastErr   error
+	reporting int32
+}
+
 // validateGraylogArguments checks that all obligatory parameters set,
 // that are needed to send log messages to Graylog instance.
 // The following values are must be set to send GELF messages to Graylog:
 //   - Level (int) syslog level
 //   - GraylogHost (string) the domain name of the Graylog instance
 //   - GraylogPort (string) the port number of the Graylog instance
+//     (or at least one of GraylogEndpoints)
 //   - GraylogProvider (string) the name of the service which sends the messages
-//   - GraylogProtocol (Transport) TCP or UDP
+//   - GraylogProtocol (Transport) TCP, UDP, TLS, HTTP or HTTPS
 func (g *GrayLogger) validateGraylogArguments(level int) bool {
-	return g.isSetGraylogObligatoryFields() && g.checkHostIsAlive() && g.IsAllowedOutput()
+	return g.isSetGraylogObligatoryFields() && g.IsAllowedOutput()
 }
 
 // isSetGraylogObligatoryFields checks that all Graylog related obligatory fields are set .
 func (g *GrayLogger) isSetGraylogObligatoryFields() bool {
-	return g.level != 0 &&
-		g.initData.GraylogHost != "" &&
-		g.initData.GraylogPort != 0 &&
+	return g.logLevel() != 0 &&
+		len(g.initData.endpoints()) > 0 &&
 		g.initData.GraylogProvider != "" &&
 		g.initData.GraylogProtocol != ""
 }
 
-// connect instantiates a new graylog connection using the given endpoint.
-func (g *GrayLogger) connect() *GrayLogger {
-	g.graylog, _ = graylog.NewGraylog(graylog.Endpoint{
-		Transport: graylog.Transport(g.initData.GraylogProtocol),
-		Address:   g.initData.GraylogHost,
-		Port:      uint(g.initData.GraylogPort),
+// connect opens the connection of the Graylog endpoint, if it is not established yet.
+// The dial is given up, when the context is done or Init.GraylogTimeout is elapsed.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) connect(ctx context.Context, e *endpoint) error {
+	if e.sender != nil {
+		return nil
+	}
+
+	switch g.initData.GraylogProtocol {
+	case TransportHTTP, TransportHTTPS:
+		return g.connectHTTP(e)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.initData.GraylogTimeout)
+	defer cancel()
+
+	switch g.initData.GraylogProtocol {
+	case TransportTLS:
+		return g.connectTLS(ctx, e)
+	case TransportUDP:
+		return g.connectUDP(ctx, e)
+	}
+
+	c, err := (&net.Dialer{}).DialContext(ctx,
+		fmt.Sprint(g.initData.GraylogProtocol),
+		e.String())
+	if err != nil {
+		return err
+	}
+
+	e.sender = &streamSender{conn: c}
+	return nil
+}
+
+// connectAny opens the connection of the first Graylog endpoint, which can be reached.
+// The unreachable endpoints are marked unhealthy, unless the context is done: then the health is not changed.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) connectAny(ctx context.Context) error {
+	err := errNoEndpoint
+	for _, e := range g.candidates(false) {
+		if err = g.connect(ctx, e); err == nil {
+			g.markUp(e)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+		g.markDown(e, deliveryError(DeliveryConnect, err))
+	}
+	return err
+}
+
+// Send writes a GELF message into the connection, the write is interrupted when the context is done.
+func (s *streamSender) Send(ctx context.Context, m GELFMessage) error {
+	payload, err := encodeGELF(m)
+	if err != nil {
+		return deliveryError(DeliveryEncode, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	release := watchContext(ctx, s.conn)
+	_, err = s.conn.Write(append(payload, 0))
+	release()
+
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Close closes the TCP or TLS connection.
+func (s *streamSender) Close() error {
+	return s.conn.Close()
+}
+
+// disconnect closes the connections of all Graylog endpoints, and returns with the first error.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) disconnect() error {
+	var first error
+	for _, e := range g.conn.endpoints {
+		if err := g.disconnectEndpoint(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// disconnectEndpoint closes the connection of the Graylog endpoint, if it is established.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) disconnectEndpoint(e *endpoint) error {
+	if e.sender == nil {
+		return nil
+	}
+
+	err := e.sender.Close()
+	e.sender = nil
+	return err
+}
+
+// write sends a GELF message to the Graylog endpoints chosen by Init.GraylogEndpointStrategy, until the context is done.
+// If the connect or the write of an endpoint fails, it is marked unhealthy and the message is sent to the next one.
+// The done context and the status of the GELF HTTP input are returned immediately, the health of the endpoint is not changed.
+// The error is a *DeliveryError, which tells whether the connect, the write or the encoding has failed.
+// The write goes through the circuit breaker, if it is enabled.
+func (g *GrayLogger) write(ctx context.Context, m GELFMessage) error {
+	return g.guarded(func() error {
+		g.conn.Lock()
+		candidates := g.candidates(true)
+		g.conn.Unlock()
+
+		err := deliveryError(DeliveryConnect, errNoEndpoint)
+		for _, e := range candidates {
+			err = g.writeEndpoint(ctx, e, m)
+			if err == nil {
+				g.conn.Lock()
+				g.markUp(e)
+				g.conn.Unlock()
+				return nil
+			}
+
+			if ctx.Err() != nil || isDeliveryOp(err, DeliveryEncode) || isHTTPStatusError(err) {
+				return err
+			}
+
+			g.conn.Lock()
+			g.markDown(e, err)
+			g.conn.Unlock()
+		}
+		return err
+	})
+}
+
+// writeEndpoint sends a GELF message through the established connection of the Graylog endpoint.
+// If the write fails, the connection is re-opened and the message is sent once again,
+// unless the GELF HTTP input has responded: its status is retried by the httpSender only.
+// The lock of g.conn is held only while the connection is opened or closed, not during the send.
+func (g *GrayLogger) writeEndpoint(ctx context.Context, e *endpoint, m GELFMessage) error {
+	sender, err := g.endpointSender(ctx, e)
+	if err != nil {
+		return deliveryError(DeliveryConnect, err)
+	}
+
+	err = sender.Send(ctx, m)
+	if err == nil || isDeliveryOp(err, DeliveryEncode) {
+		return err
+	}
+
+	if isHTTPStatusError(err) {
+		return deliveryError(DeliveryWrite, err)
+	}
+
+	// The connection may be left in the middle of a message, so it is never reused.
+	// The error of closing the broken connection is superseded by the write error.
+	g.dropSender(e, sender)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return deliveryError(DeliveryWrite, ctxErr)
+	}
+
+	if sender, err = g.endpointSender(ctx, e); err != nil {
+		return deliveryError(DeliveryConnect, err)
+	}
+
+	return deliveryError(DeliveryWrite, sender.Send(ctx, m))
+}
+
+// endpointSender returns with the sender of the Graylog endpoint, the connection is opened if it is not established yet.
+func (g *GrayLogger) endpointSender(ctx context.Context, e *endpoint) (gelfSender, error) {
+	g.conn.Lock()
+	defer g.conn.Unlock()
+
+	if err := g.connect(ctx, e); err != nil {
+		return nil, err
+	}
+	return e.sender, nil
+}
+
+// dropSender closes the broken connection of the Graylog endpoint,
+// unless it has been replaced by another message in the meantime.
+func (g *GrayLogger) dropSender(e *endpoint, sender gelfSender) {
+	g.conn.Lock()
+	defer g.conn.Unlock()
+
+	if e.sender == sender {
+		_ = g.disconnectEndpoint(e)
+	}
+}
+
+// send writes a GELF message with retries, and reports it if it could not be delivered.
+// While the spool is not replayed completely, the message is appended to the spool to keep the order of the messages.
+func (g *GrayLogger) send(ctx context.Context, m GELFMessage) error {
+	if g.spool != nil && g.spool.backlogged() {
+		return g.spoolMessage(m)
+	}
+
+	err := g.retry(ctx, func() error {
+		return g.write(ctx, m)
+	})
+	if err != nil {
+		return g.lost(err, m)
+	}
+	return nil
+}
+
+// lost stores a GELF message, which could not be delivered, into the spool if it is enabled,
+// otherwise the message is reported. The messages which could not be encoded are never spooled.
+func (g *GrayLogger) lost(err error, m GELFMessage) error {
+	if g.spool != nil && !isDeliveryOp(err, DeliveryEncode) {
+		return g.spoolMessage(m)
+	}
+
+	g.reportError(err, m)
+	return err
+}
+
+// spoolMessage appends a GELF message to the spool, and reports it if it could not be stored.
+func (g *GrayLogger) spoolMessage(m GELFMessage) error {
+	err := deliveryError(DeliverySpool, g.spool.append(m))
+	if err != nil {
+		g.reportError(err, m)
+	}
+	return err
+}
+
+// deliver sends a GELF message immediately, or puts it into the asynchronous queue if it is enabled.
+// The message larger than the maximum message size is truncated first.
+// The queued messages are reported by the queue, if they could not be delivered.
+func (g *GrayLogger) deliver(ctx context.Context, m GELFMessage) error {
+	if max := g.maxMessageSize(); max > 0 {
+		m = truncateGELF(m, max)
+	}
+
+	if g.queue != nil {
+		g.queue.push(ctx, m)
+		return nil
+	}
+	return g.send(ctx, m)
+}
+
+// sendGELFTracked is the SendGELFContext with given tracking information of the caller,
+// where fields are the fields pulled out of the context and the fields of the child logger (see boundFields).
+// Nothing is sent, if the context is already done, or if it is called by Init.GraylogOnError.
+// If the Graylog host is unreachable, all messages of the log call are spooled or reported as lost.
+// While the spool is replayed, the host is not checked: the messages are appended to the spool.
+// With Init.GraylogAsync the host is not checked either, the messages are only queued:
+// the workers connect, and spool or report the messages which could not be delivered.
+// It returns with the first delivery error.
+func (g *GrayLogger) sendGELFTracked(ctx context.Context, level int, tr TrackInfo, keysAndValues []interface{}, fields []keyValue) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !g.validateGraylogArguments(level) {
+		return nil
+	}
+
+	// The messages logged by Init.GraylogOnError are not sent,
+	// they would report their own delivery errors while Graylog is unreachable.
+	if g.inOnError() {
+		return nil
+	}
+
+	messages := g.gelfMessages(level, tr, keysAndValues, fields)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var first error
+	if g.queue == nil && (g.spool == nil || !g.spool.backlogged()) {
+		if err := g.checkHostIsAlive(ctx); err != nil {
+			for _, m := range messages {
+				if err := g.lost(err, m); err != nil && first == nil {
+					first = err
+				}
+			}
+			return first
+		}
+	}
+
+	for _, m := range messages {
+		if err := g.deliver(ctx, m); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// gelfMessages creates one GELF message per key : value pair.
+// If Init.GraylogStructured is enabled, all pairs are put into one GELF message.
+// The given fields of the child logger and of the context are added to every message.
+// The messages are customized by Init.GraylogGELFBuilder, if it is set.
+// No message is created, if the level is not logged.
+func (g *GrayLogger) gelfMessages(level int, tr TrackInfo, keysAndValues []interface{}, fields []keyValue) []GELFMessage {
+	if g.logLevel() < level {
+		return nil
+	}
+
+	messages := g.defaultMessages(level, tr, keysAndValues, fields)
+
+	if g.initData.GraylogGELFBuilder != nil {
+		r := g.newRecord(level, tr, append(keysAndValuesToPairs(keysAndValues), fields...))
+		for i, m := range messages {
+			messages[i] = g.initData.GraylogGELFBuilder(r, m)
+		}
+	}
+	return messages
+}
+
+// defaultMessages creates the GELF messages of a log call, before they are customized by Init.GraylogGELFBuilder.
+// The static fields are added to every message first, so the colliding key : value pairs are numbered.
+func (g *GrayLogger) defaultMessages(level int, tr TrackInfo, keysAndValues []interface{}, fields []keyValue) []GELFMessage {
+	if g.initData.GraylogStructured {
+		return []GELFMessage{g.structuredMessage(level, keysAndValues, fields, tr)}
+	}
+
+	var messages []GELFMessage
+	for _, kv := range keysAndValuesToPairs(keysAndValues) {
+		extra := createExtraFieldsMap(GraylogExtraFields{
+			Env:      g.initData.LogEnv,
+			Level:    logLevelToString(level),
+			Key:      prettifyObject(kv.key),
+			Value:    prettifyObject(kv.value),
+			Line:     tr.Line,
+			File:     tr.File,
+			Function: tr.Function,
+		})
+		addExtraFields(extra, g.static)
+		addExtraFields(extra, fields)
+
+		messages = append(messages, GELFMessage{
+			Version:      "1.1",
+			Host:         g.initData.GraylogProvider,
+			ShortMessage: prettifyKeyVal(keyValToSlice(kv.key, cleanString(fmt.Sprint(kv.value)))),
+			FullMessage:  prettifyKeyVal(keyValToSlice(kv.key, kv.value)),
+			Timestamp:    time.Now(),
+			Level:        uint(level),
+			Extra:        extra,
+		})
+	}
+	return messages
+}
+
+// structuredMessage creates one GELF message, where every key : value pair
+// is an additional field named after its key.
+// For example:
+//  g.Info("user", 42, "status", "ok") -> _user: 42, _status: ok
+func (g *GrayLogger) structuredMessage(level int, keysAndValues []interface{}, fields []keyValue, tr TrackInfo) GELFMessage {
+	message := prettifyKeyVal(keyValToSlice(keysAndValues...))
+	return GELFMessage{
+		Version:      "1.1",
+		Host:         g.initData.GraylogProvider,
+		ShortMessage: cleanString(message),
+		FullMessage:  message,
+		Timestamp:    time.Now(),
+		Level:        uint(level),
+		Extra:        structuredExtraFields(g.initData.LogEnv, level, tr, g.static, keysAndValuesToPairs(keysAndValues), fields),
+	}
+}
+
+// structuredExtraFields creates the additional fields of a structured GELF message,
+// where every key : value pair is an additional field named after its key.
+func structuredExtraFields(env string, level int, tr TrackInfo, pairs ...[]keyValue) map[string]string {
+	extra := createExtraFieldsMap(GraylogExtraFields{
+		Env:      env,
+		Level:    logLevelToString(level),
+		Line:     tr.Line,
+		File:     tr.File,
+		Function: tr.Function,
 	})
-	return g
-}
-
-// send iterates over key : value pairs
-// and send them to Graylog instance one by one as a GELF message.
-func (g *GrayLogger) send(level int, keysAndValues []interface{}) {
-	tr := getTrackingInfo(3)
-	for key, val := range keysAndValuesToMap(keysAndValues) {
-		if g.graylog != nil && g.level >= level {
-			_ = g.graylog.Send(graylog.Message{
-				Version:      "1.1",
-				Host:         g.initData.GraylogProvider,
-				ShortMessage: prettifyKeyVal(keyValToSlice(key, cleanString(fmt.Sprint(val)))),
-				FullMessage:  prettifyKeyVal(keyValToSlice(key, val)),
-				Timestamp:    time.Now().Unix(),
-				Level:        uint(level),
-				Extra: createExtraFieldsMap(GraylogExtraFields{
-					Env:      g.initData.LogEnv,
-					Level:    logLevelToString(level),
-					Key:      prettifyObject(key),
-					Value:    prettifyObject(val),
-					Line:     tr.Line,
-					File:     tr.File,
-					Function: tr.Function,
-				}),
-			})
-			_ = g.graylog.Close()
+	delete(extra, "log_key")
+	delete(extra, "log_value")
+
+	for _, p := range pairs {
+		addExtraFields(extra, p)
+	}
+	return extra
+}
+
+// addExtraFields adds key : value pairs to the additional fields of a GELF message.
+// The keys colliding with the fields of GraylogExtraFields are prefixed by "field_",
+// and the duplicate keys are numbered.
+func addExtraFields(extra map[string]string, pairs []keyValue) {
+	for _, kv := range pairs {
+		name := gelfFieldName(kv.key)
+		if _, reserved := reservedExtraFields[name]; reserved {
+			name = "field_" + name
+		}
+		extra[uniqueFieldName(extra, name)] = strings.TrimSpace(prettifyObject(kv.value))
+	}
+}
+
+// gelfFieldName converts a key into a valid name of a GELF additional field (without the leading underscore).
+// The characters other than letters, digits, underscores, dashes and dots are replaced by underscores,
+// and the reserved "id" field is renamed.
+// For example:
+//  user id -> user_id
+//  id -> field_id
+func gelfFieldName(key string) string {
+	name := []rune(key)
+	for i, r := range name {
+		if !(r == '_' || r == '-' || r == '.' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			name[i] = '_'
+		}
+	}
+
+	switch string(name) {
+	case "":
+		return "field"
+	case "id":
+		return "field_id"
+	}
+	return string(name)
+}
+
+// uniqueFieldName numbers the name of a duplicate additional field,
+// so the values of duplicate keys are all kept.
+// For example:
+//  user, user -> user, user_2
+func uniqueFieldName(extra map[string]string, name string) string {
+	if _, exists := extra[name]; !exists {
+		return name
+	}
+
+	for i := 2; ; i++ {
+		numbered := fmt.Sprintf("%s_%d", name, i)
+		if _, exists := extra[numbered]; !exists {
+			return numbered
 		}
 	}
 }
 
 // checkHostIsAlive validates Graylog host connection.
-func (g *GrayLogger) checkHostIsAlive() bool {
-	_, err := net.DialTimeout(
-		fmt.Sprint(g.initData.GraylogProtocol),
-		g.initData.GraylogHost+":"+fmt.Sprint(g.initData.GraylogPort),
-		g.initData.GraylogTimeout)
-	if err != nil {
+// It opens the connection of a Graylog endpoint if needed, which is kept and reused by the later log calls.
+// The connect is retried, if Init.GraylogRetries is set, and it goes through the circuit breaker if it is enabled.
+// If the connection could not be opened, it writes an error message to stdOut and returns with a *DeliveryError.
+func (g *GrayLogger) checkHostIsAlive(ctx context.Context) error {
+	err := g.retry(ctx, func() error {
+		return g.guarded(func() error {
+			g.conn.Lock()
+			defer g.conn.Unlock()
+
+			return g.connectAny(ctx)
+		})
+	})
+
+	// The open circuit breaker has logged its state already.
+	if err != nil && !errors.Is(err, errBreakerOpen) {
 		couldNotConnect := "could not connect to Graylog host with initialized data"
-		errorMsg := []interface{}{couldNotConnect, g.initData}
-		g.functions.Error.Println(g.formatLogLine(errorMsg...))
+		errorMsg := []interface{}{couldNotConnect, g.GetInit().redacted()}
+		g.functions.Error.Println(g.formatLogLine(levelErrorNum, errorMsg...))
 	}
-	return err == nil
+	return deliveryError(DeliveryConnect, err)
+}
+
+// encodeGELF creates the JSON payload of a GELF message,
+// where the extra fields are prefixed by an underscore.
+func encodeGELF(m GELFMessage) ([]byte, error) {
+	payload := map[string]interface{}{
+		"version":       m.Version,
+		"host":          m.Host,
+		"short_message": m.ShortMessage,
+	}
+
+	if m.FullMessage != "" {
+		payload["full_message"] = m.FullMessage
+	}
+
+	if !m.Timestamp.IsZero() {
+		payload["timestamp"] = gelfTimestamp(m.Timestamp)
+	}
+
+	if m.Level != 0 {
+		payload["level"] = m.Level
+	}
+
+	for key, value := range m.Extra {
+		payload["_"+key] = value
+	}
+
+	return json.Marshal(payload)
+}
+
+// maxMessageSize returns with the maximum size of an encoded GELF message, or 0 if it is unlimited.
+// With TransportUDP and ChunkLimitTruncate, the message must fit into the GELF chunks as well.
+func (g *GrayLogger) maxMessageSize() int {
+	max := g.initData.GraylogMaxMessageSize
+	if g.initData.GraylogProtocol != TransportUDP || g.initData.GraylogUDPChunkLimit != ChunkLimitTruncate {
+		return max
+	}
+
+	chunked := gelfMaxChunks * (g.initData.GraylogUDPChunkSize - gelfChunkHeaderSize)
+	if max <= 0 || chunked < max {
+		return chunked
+	}
+	return max
+}
+
+// truncateGELF trims a GELF message, until its encoded size fits into max bytes.
+// The full message, the short message and the additional fields are cut to the same length,
+// so the long values (e.g. a pretty-printed object in the full message) are trimmed first,
+// and the short fields (e.g. _log_level) are kept intact.
+// The trimmed message is marked by the _truncated additional field.
+func truncateGELF(m GELFMessage, max int) GELFMessage {
+	overflow := func() int {
+		payload, err := encodeGELF(m)
+		if err != nil {
+			return 0
+		}
+		return len(payload) - max
+	}
+
+	if overflow() <= 0 {
+		return m
+	}
+
+	original := m
+	longest := len(m.FullMessage)
+	if len(m.ShortMessage) > longest {
+		longest = len(m.ShortMessage)
+	}
+	for _, value := range m.Extra {
+		if len(value) > longest {
+			longest = len(value)
+		}
+	}
+
+	extra := make(map[string]string, len(m.Extra)+1)
+	m.Extra = extra
+
+	cut := func(n int) {
+		m.FullMessage = cutString(original.FullMessage, n)
+		m.ShortMessage = cutString(original.ShortMessage, n)
+		for key, value := range original.Extra {
+			extra[key] = cutString(value, n)
+		}
+		extra[truncatedField] = "true"
+	}
+
+	// Binary search for the longest length, where the message fits.
+	low, high := 0, longest
+	for low < high {
+		mid := (low + high + 1) / 2
+		cut(mid)
+		if overflow() <= 0 {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	cut(low)
+
+	return m
+}
+
+// cutString cuts the string to at most n bytes, without splitting a multi-byte character.
+func cutString(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	if n >= len(text) {
+		return text
+	}
+
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// gelfTimestamp converts the time into seconds since the UNIX epoch with microsecond precision.
+// For example:
+//  2020-01-27 13:16:54.123456789 UTC -> 1580131014.123456
+func gelfTimestamp(t time.Time) json.Number {
+	return json.Number(fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond)))
+}
+
+// redacted returns with a copy of the Init, where the credentials are masked,
+// so it can be written into the log.
+func (i Init) redacted() Init {
+	if i.GraylogHTTPPassword != "" {
+		i.GraylogHTTPPassword = redactedText
+	}
+
+	if i.GraylogHTTPBearerToken != "" {
+		i.GraylogHTTPBearerToken = redactedText
+	}
+
+	// The headers typically hold API keys, e.g. Authorization or X-API-Key, so all values are masked.
+	// The map is copied, the headers of the logger are kept intact.
+	if len(i.GraylogHTTPHeaders) > 0 {
+		headers := make(map[string]string, len(i.GraylogHTTPHeaders))
+		for name := range i.GraylogHTTPHeaders {
+			headers[name] = redactedText
+		}
+		i.GraylogHTTPHeaders = headers
+	}
+
+	return i
 }
 
 // cleanString removes whitespaces and newlines from a string.
@@ -108,17 +722,32 @@ func cleanString(text string) string {
 	return strings.Join(strings.Fields(strings.TrimSpace(strings.ReplaceAll(text, "\n", ""))), " ")
 }
 
-// keysAndValuesToMap creates multiple key : value pairs
+// badKey is the key of a dangling value, which has no pair in keysAndValues.
+const badKey = "!BADKEY"
+
+// keyValue holds one key : value pair of the keysAndValues argument of the logger functions.
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+// keysAndValuesToPairs creates multiple key : value pairs
 // if more than one are passed as an argument into logger function.
-// After that, we can send these pairs into Graylog instance one by one.
-func keysAndValuesToMap(keysAndValues []interface{}) map[interface{}]interface{} {
-	m := make(map[interface{}]interface{})
-	for i, v := range keysAndValues {
-		if i%2 == 0 {
-			m[v] = keysAndValues[i+1]
+// The order of the pairs is preserved and duplicate keys are kept.
+// Non-string keys are formatted as strings, and a dangling value
+// (odd number of arguments) is recorded under the !BADKEY key.
+// For example:
+//  "user", 42, "dangling" -> user : 42, !BADKEY : dangling
+func keysAndValuesToPairs(keysAndValues []interface{}) []keyValue {
+	pairs := make([]keyValue, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			pairs = append(pairs, keyValue{key: badKey, value: keysAndValues[i]})
+			break
 		}
+		pairs = append(pairs, keyValue{key: fmt.Sprintf("%+v", keysAndValues[i]), value: keysAndValues[i+1]})
 	}
-	return m
+	return pairs
 }
 
 // prettifyKeyVal converts a string slice to a formatted string.
@@ -142,6 +771,10 @@ func createExtraFieldsMap(extra GraylogExtraFields) map[string]string {
 // prettifyObject create a JSON string if it possible
 // to make any structures human-readable in Graylog.
 func prettifyObject(obj interface{}) string {
+	if obj == nil {
+		return fmt.Sprint(obj)
+	}
+
 	switch reflect.TypeOf(obj).Kind() {
 	case reflect.Struct:
 		return makePrettifiedJSON(obj)
@@ -3,6 +3,7 @@ package graylogger
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -94,6 +95,61 @@ func (s graylogHelpersSuite) TestPrettifyObject() {
 
 	pretty = cleanString(prettifyObject(any))
 	s.Equal("101", pretty)
+
+	// 5. From nil ...
+	pretty = prettifyObject(nil)
+	s.Equal("<nil>", pretty)
+}
+
+func (s graylogHelpersSuite) TestKeysAndValuesToPairs() {
+	// 1. The order is preserved ...
+	pairs := keysAndValuesToPairs([]interface{}{"c", 1, "b", 2, "a", 3})
+	s.Equal([]keyValue{{"c", 1}, {"b", 2}, {"a", 3}}, pairs)
+
+	// 2. Duplicate keys are kept ...
+	pairs = keysAndValuesToPairs([]interface{}{"a", 1, "a", 2})
+	s.Equal([]keyValue{{"a", 1}, {"a", 2}}, pairs)
+
+	// 3. Non-string keys are formatted ...
+	pairs = keysAndValuesToPairs([]interface{}{42, "int", []int{1, 2}, "slice", nil, "nil"})
+	s.Equal([]keyValue{{"42", "int"}, {"[1 2]", "slice"}, {"<nil>", "nil"}}, pairs)
+
+	// 4. A dangling value is recorded under the !BADKEY key ...
+	pairs = keysAndValuesToPairs([]interface{}{"a", 1, "dangling"})
+	s.Equal([]keyValue{{"a", 1}, {badKey, "dangling"}}, pairs)
+
+	// 5. No arguments at all.
+	pairs = keysAndValuesToPairs(nil)
+	s.Equal([]keyValue{}, pairs)
+}
+
+func (s graylogHelpersSuite) TestUniqueFieldName() {
+	extra := map[string]string{"user": "1", "user_2": "2"}
+	s.Equal("order", uniqueFieldName(extra, "order"))
+	s.Equal("user_3", uniqueFieldName(extra, "user"))
+}
+
+func (s graylogHelpersSuite) TestGelfFieldName() {
+	s.Equal("user", gelfFieldName("user"))
+	s.Equal("user_id", gelfFieldName("user id"))
+	s.Equal("http.status-code", gelfFieldName("http.status-code"))
+	s.Equal("a_b_c", gelfFieldName("a/b:c"))
+	s.Equal("field_id", gelfFieldName("id"))
+	s.Equal("field", gelfFieldName(""))
+}
+
+func (s graylogHelpersSuite) TestGelfTimestamp() {
+	t := time.Date(2020, 1, 27, 13, 16, 54, 123456789, time.UTC)
+	s.Equal(json.Number("1580131014.123456"), gelfTimestamp(t))
+	s.Equal(json.Number("1580131014.000001"), gelfTimestamp(t.Truncate(time.Second).Add(time.Microsecond)))
+
+	payload, err := encodeGELF(GELFMessage{Version: "1.1", ShortMessage: "test", Timestamp: t})
+	s.Equal(nil, err)
+	s.Equal(`{"host":"","short_message":"test","timestamp":1580131014.123456,"version":"1.1"}`, string(payload))
+
+	payload, err = encodeGELF(GELFMessage{Version: "1.1", ShortMessage: "test"})
+	s.Equal(nil, err)
+	s.Equal(`{"host":"","short_message":"test","version":"1.1"}`, string(payload))
 }
 
 func TestGraylogHelpersSuite(t *testing.T) {
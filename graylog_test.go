@@ -1,10 +1,14 @@
 package graylogger
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,11 +26,11 @@ func (s graylogSuite) TestSendGELF() {
 	init.GraylogProvider = "TestService"
 	init.GraylogProtocol = TransportUDP
 
-	var response string
+	responses := make(chan string, 1)
 	go func() {
 		resp, err := udpServer(init.GraylogPort)
 		s.Equal(nil, err)
-		response = resp
+		responses <- resp
 	}()
 
 	time.Sleep(10 * time.Millisecond)
@@ -34,34 +38,337 @@ func (s graylogSuite) TestSendGELF() {
 	g := New(init)
 	g.Info("test", "info")
 
+	response := <-responses
+	obj := map[string]interface{}{}
+
+	// Removing NUL characters from bytes
+	responseBytes := bytes.Trim([]byte(response), "\x00")
+
+	err := json.Unmarshal(responseBytes, &obj)
+	s.Equal(nil, err)
+
+	s.Equal("test", obj["_log_env"])
+	s.Equal("test", obj["_log_key"])
+	s.Equal("info", obj["_log_level"])
+	s.Equal("info", obj["_log_value"])
+	s.Equal("graylog_test.go", obj["_track_file"])
+	s.Equal("graylogger.graylogSuite.TestSendGELF", obj["_track_function"])
+	s.NotEqual("", obj["_track_line"])
+	s.NotEqual("", obj["timestamp"])
+	s.Equal("test :: info", obj["full_message"])
+	s.Equal("test :: info", obj["short_message"])
+	s.Equal("TestService", obj["host"])
+	s.Equal("1.1", obj["version"])
+	s.Equal(float64(6), obj["level"])
+}
+
+func (s graylogSuite) TestSendGELFReusesConnection() {
+	server := newTCPServer(s)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	g.SendGELF(levelInfoNum, "first", "message")
+	g.SendGELF(levelInfoNum, "second", "message")
+	g.SendGELF(levelInfoNum, "third", "message")
+
+	s.Equal("first :: message", server.message()["short_message"])
+	s.Equal("second :: message", server.message()["short_message"])
+	s.Equal("third :: message", server.message()["short_message"])
+	s.Equal(1, server.accepted())
+
+	// After Close(), the next message opens a new connection.
+	s.Equal(nil, g.Close())
+	g.SendGELF(levelInfoNum, "fourth", "message")
+
+	s.Equal("fourth :: message", server.message()["short_message"])
+	s.Equal(2, server.accepted())
+
+	s.Equal(nil, g.Close())
+	s.Equal(nil, g.Close())
+
+	g.SaveOutput()
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s graylogSuite) TestSendGELFReconnects() {
+	server := newTCPServer(s)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	defer func() {
+		_ = g.Close()
+	}()
+
+	g.CaptureOutput(testOutputFileName)
+	g.SendGELF(levelInfoNum, "before", "reconnect")
+	s.Equal("before :: reconnect", server.message()["short_message"])
+
+	// Dropping the connection on the server side ...
+	server.dropConnections()
+
+	// ... the logger re-opens it transparently.
+	deadline := time.Now().Add(5 * time.Second)
+	for server.accepted() < 2 && time.Now().Before(deadline) {
+		g.SendGELF(levelInfoNum, "after", "reconnect")
+		time.Sleep(10 * time.Millisecond)
+	}
+	g.SaveOutput()
+
+	s.Equal(2, server.accepted())
+	s.Equal("after :: reconnect", server.lastMessage()["short_message"])
+	s.Equal(false, strings.Contains(g.GetOutput(), "could not connect"))
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s graylogSuite) TestSendGELFStructured() {
+	server := newTCPServer(s)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStructured = true
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42, "order id", "A-1", "log_env", "override", "status", map[string]string{"a": "b"})
+
+	obj := server.message()
+	s.Equal("42", obj["_user"])
+	s.Equal("A-1", obj["_order_id"])
+	s.Equal("override", obj["_field_log_env"])
+	s.Equal(`{"a":"b"}`, cleanString(fmt.Sprint(obj["_status"])))
+	s.Equal("test", obj["_log_env"])
+	s.Equal("info", obj["_log_level"])
+	s.Equal("graylog_test.go", obj["_track_file"])
+	s.Equal("graylogger.graylogSuite.TestSendGELFStructured", obj["_track_function"])
+	s.Equal(nil, obj["_log_key"])
+	s.Equal(nil, obj["_log_value"])
+	s.Equal("user :: 42 :: order id :: A-1 :: log_env :: override :: status :: map[a:b]", obj["short_message"])
+	s.Equal(float64(6), obj["level"])
+	s.InDelta(float64(time.Now().UnixNano())/float64(time.Second), obj["timestamp"], 5)
+
+	// Exactly one GELF message is sent per log call.
+	g.Info("second", "call")
+	s.Equal("second :: call", server.message()["short_message"])
+
+	// Duplicate keys and a dangling value are kept.
+	g.Info("user", 1, "user", 2, "dangling")
+	obj = server.message()
+	s.Equal("1", obj["_user"])
+	s.Equal("2", obj["_user_2"])
+	s.Equal("dangling", obj["__BADKEY"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s graylogSuite) TestSendGELFKeysAndValues() {
+	server := newTCPServer(s)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	// The pairs are sent in order, and the odd number of arguments doesn't panic.
+	s.NotPanics(func() {
+		g.Info("c", 1, "b", 2, "a", 3, []string{"unhashable"}, nil, "dangling")
+	})
+
+	s.Equal("c :: 1", server.message()["short_message"])
+	s.Equal("b :: 2", server.message()["short_message"])
+	s.Equal("a :: 3", server.message()["short_message"])
+	s.Equal("[unhashable] :: <nil>", server.message()["short_message"])
+
+	obj := server.message()
+	s.Equal("!BADKEY :: dangling", obj["short_message"])
+	s.Equal("!BADKEY", obj["_log_key"])
+	s.Equal("dangling", obj["_log_value"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s graylogSuite) TestSendGELFWith() {
+	server := newTCPServer(s)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	child := g.With("request_id", "r-1", "log_env", "bound")
+	child.Info("key", "value")
+
+	obj := server.message()
+	s.Equal("key :: value", obj["short_message"])
+	s.Equal("key", obj["_log_key"])
+	s.Equal("r-1", obj["_request_id"])
+	s.Equal("bound", obj["_field_log_env"])
+	s.Equal("test", obj["_log_env"])
+	s.Equal("graylogger.graylogSuite.TestSendGELFWith", obj["_track_function"])
+
+	// The child shares the connection of its parent.
+	g.Info("parent", "message")
+	obj = server.message()
+	s.Equal("parent :: message", obj["short_message"])
+	s.Equal(nil, obj["_request_id"])
+	s.Equal(1, server.accepted())
+
+	// In structured mode, the bound fields are next to the fields of the log call.
+	init.GraylogStructured = true
+	structured := New(init).With("tenant", "acme")
+	structured.CaptureOutput(testOutputFileName)
+	structured.Info("user", 42)
+
+	obj = server.message()
+	s.Equal("42", obj["_user"])
+	s.Equal("acme", obj["_tenant"])
+	s.Equal("user :: 42", obj["short_message"])
+
+	s.Equal(nil, g.Close())
+	s.Equal(nil, structured.Close())
+	g.SaveOutput()
+	structured.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+// tcpServer is a minimal Graylog TCP input for tests,
+// it collects the NUL delimited GELF messages of all accepted connections.
+type tcpServer struct {
+	s        graylogSuite
+	listener net.Listener
+	messages chan map[string]interface{}
+
+	mu    sync.Mutex
+	conns []net.Conn
+	count int
+	last  map[string]interface{}
+}
+
+func newTCPServer(s graylogSuite) *tcpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+
+	return newListenerServer(s, l)
+}
+
+func newListenerServer(s graylogSuite, l net.Listener) *tcpServer {
+	t := &tcpServer{s: s, listener: l, messages: make(chan map[string]interface{}, 1024)}
+	go t.serve()
+	return t
+}
+
+func (t *tcpServer) serve() {
 	for {
-		if response != "" {
-			obj := map[string]interface{}{}
-
-			// Removing NUL characters from bytes
-			responseBytes := bytes.Trim([]byte(response), "\x00")
-
-			err := json.Unmarshal(responseBytes, &obj)
-			s.Equal(nil, err)
-
-			s.Equal("test", obj["_log_env"])
-			s.Equal("test", obj["_log_key"])
-			s.Equal("info", obj["_log_level"])
-			s.Equal("info", obj["_log_value"])
-			s.Equal("graylog_test.go", obj["_track_file"])
-			s.Equal("graylogger.graylogSuite.TestSendGELF", obj["_track_function"])
-			s.NotEqual("", obj["_track_line"])
-			s.NotEqual("", obj["timestamp"])
-			s.Equal("test :: info", obj["full_message"])
-			s.Equal("test :: info", obj["short_message"])
-			s.Equal("TestService", obj["host"])
-			s.Equal("1.1", obj["version"])
-			s.Equal(float64(6), obj["level"])
-
-			break
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		t.mu.Lock()
+		t.conns = append(t.conns, conn)
+		t.count++
+		t.mu.Unlock()
+
+		go t.read(conn)
+	}
+}
+
+func (t *tcpServer) read(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		b, err := r.ReadBytes(0)
+		if err != nil {
+			return
+		}
+
+		obj := map[string]interface{}{}
+		if json.Unmarshal(bytes.Trim(b, "\n\x00"), &obj) == nil {
+			t.mu.Lock()
+			t.last = obj
+			t.mu.Unlock()
+			t.messages <- obj
 		}
 	}
+}
+
+func (t *tcpServer) port() int {
+	return t.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (t *tcpServer) accepted() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
+
+func (t *tcpServer) message() map[string]interface{} {
+	select {
+	case m := <-t.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.s.Fail("no GELF message received")
+		return nil
+	}
+}
+
+func (t *tcpServer) lastMessage() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+func (t *tcpServer) dropConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.conns {
+		_ = c.Close()
+	}
+	t.conns = nil
+}
 
+func (t *tcpServer) close() {
+	_ = t.listener.Close()
+	t.dropConnections()
 }
 
 func udpServer(port int) (string, error) {
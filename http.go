@@ -0,0 +1,183 @@
+package graylogger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// graylogHTTPPath declares the default URL path of the GELF HTTP input.
+	graylogHTTPPath = "/gelf"
+
+	// graylogHTTPTimeout declares the maximum amount of time a GELF HTTP request may take.
+	graylogHTTPTimeout = 5 * time.Second
+)
+
+// httpSender posts GELF messages to the GELF HTTP input of Graylog.
+type httpSender struct {
+	client      *http.Client
+	url         string
+	headers     map[string]string
+	username    string
+	password    string
+	bearerToken string
+	retries     int
+	backoff     func(attempt int) time.Duration
+
+	compression      Compression
+	compressionLevel int
+}
+
+// initHTTP sets the defaults of the GELF HTTP transport.
+func (g *GrayLogger) initHTTP() {
+	if g.initData.GraylogHTTPPath == "" {
+		g.initData.GraylogHTTPPath = graylogHTTPPath
+	}
+
+	if !strings.HasPrefix(g.initData.GraylogHTTPPath, "/") {
+		g.initData.GraylogHTTPPath = "/" + g.initData.GraylogHTTPPath
+	}
+
+	if g.initData.GraylogHTTPTimeout == 0 {
+		g.initData.GraylogHTTPTimeout = graylogHTTPTimeout
+	}
+}
+
+// connectHTTP creates the GELF HTTP sender of the Graylog endpoint, the connections are kept alive by its http.Client.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) connectHTTP(e *endpoint) error {
+	e.sender = &httpSender{
+		client: &http.Client{
+			Timeout: g.initData.GraylogHTTPTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				DialContext:     (&net.Dialer{Timeout: g.initData.GraylogTimeout}).DialContext,
+				TLSClientConfig: g.tlsConfig,
+			},
+		},
+		url: fmt.Sprintf("%s://%s%s",
+			g.initData.GraylogProtocol,
+			e,
+			g.initData.GraylogHTTPPath),
+		headers:     g.initData.GraylogHTTPHeaders,
+		username:    g.initData.GraylogHTTPUsername,
+		password:    g.initData.GraylogHTTPPassword,
+		bearerToken: g.initData.GraylogHTTPBearerToken,
+		retries:     g.initData.GraylogHTTPRetries,
+		backoff:     g.retryDelay,
+
+		compression:      g.initData.GraylogCompression,
+		compressionLevel: g.initData.GraylogCompressionLevel,
+	}
+	return nil
+}
+
+// Send posts a GELF message, it is retried if the server responds with 5xx status,
+// with the delay of the retries of the GELF delivery between the attempts.
+// The requests and the delays are cancelled when the context is done.
+// If the server has not accepted the message, it returns with an *httpStatusError.
+func (h *httpSender) Send(ctx context.Context, m GELFMessage) error {
+	payload, err := encodeGELF(m)
+	if err != nil {
+		return deliveryError(DeliveryEncode, err)
+	}
+
+	payload, err = h.compression.compress(payload, h.compressionLevel)
+	if err != nil {
+		return deliveryError(DeliveryEncode, err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, err := h.post(ctx, payload)
+		if err != nil {
+			return err
+		}
+
+		if status < 300 {
+			return nil
+		}
+
+		statusErr := &httpStatusError{status: status}
+		if status < 500 || attempt >= h.retries {
+			return statusErr
+		}
+
+		if h.backoff != nil {
+			timer := time.NewTimer(h.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return statusErr
+			}
+		}
+	}
+}
+
+// httpStatusError is the error of a completed GELF HTTP request, whose status is not 2xx.
+// The request has been retried by the httpSender already, so the delivery never repeats it.
+type httpStatusError struct {
+	status int
+}
+
+// Error returns with the status of the response.
+// For example:
+//  GELF HTTP input responded with status: 503 Service Unavailable
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GELF HTTP input responded with status: %d %s", e.status, http.StatusText(e.status))
+}
+
+// isHTTPStatusError checks that the error is the status of a completed GELF HTTP request.
+func isHTTPStatusError(err error) bool {
+	var se *httpStatusError
+	return errors.As(err, &se)
+}
+
+// post makes a single GELF HTTP request and returns with the response status code.
+func (h *httpSender) post(ctx context.Context, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if encoding := h.compression.contentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for key, value := range h.headers {
+		req.Header.Set(key, value)
+	}
+
+	if h.username != "" || h.password != "" {
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	if h.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.bearerToken)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	// Draining the body lets the connection be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// Close releases the idle connections of the GELF HTTP sender.
+func (h *httpSender) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
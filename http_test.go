@@ -0,0 +1,277 @@
+package graylogger
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type httpSuite struct {
+	suite.Suite
+}
+
+func (s httpSuite) TestSendGELFOverHTTP() {
+	server := newHTTPServer(http.StatusAccepted)
+	defer server.Close()
+
+	init := server.init(s, TransportHTTP)
+	init.GraylogHTTPPath = "custom/gelf"
+	init.GraylogHTTPHeaders = map[string]string{"X-Tenant": "example"}
+	init.GraylogHTTPUsername = "user"
+	init.GraylogHTTPPassword = "secret"
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "http")
+	g.SaveOutput()
+
+	s.Equal(1, len(server.requests()))
+	r := server.requests()[0]
+	s.Equal(http.MethodPost, r.method)
+	s.Equal("/custom/gelf", r.path)
+	s.Equal("application/json", r.header.Get("Content-Type"))
+	s.Equal("example", r.header.Get("X-Tenant"))
+	s.Equal("Basic dXNlcjpzZWNyZXQ=", r.header.Get("Authorization"))
+	s.Equal("test :: http", r.body["short_message"])
+	s.Equal("test", r.body["_log_env"])
+	s.Equal("TestService", r.body["host"])
+	s.Equal(float64(6), r.body["level"])
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s httpSuite) TestSendGELFOverHTTPS() {
+	server := newHTTPSServer(http.StatusAccepted)
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "graylogger-ca")
+	s.Require().Equal(nil, err)
+	defer func() {
+		_ = os.Remove(caFile.Name())
+	}()
+
+	err = pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	s.Require().Equal(nil, err)
+	s.Require().Equal(nil, caFile.Close())
+
+	init := server.init(s, TransportHTTPS)
+	init.GraylogTLSCAFile = caFile.Name()
+	init.GraylogHTTPBearerToken = "token"
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "https")
+	g.SaveOutput()
+
+	s.Equal(1, len(server.requests()))
+	r := server.requests()[0]
+	s.Equal("/gelf", r.path)
+	s.Equal("Bearer token", r.header.Get("Authorization"))
+	s.Equal("test :: https", r.body["short_message"])
+
+	s.Equal(nil, g.Close())
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s httpSuite) TestHTTPSenderRetries() {
+	// 1. Retried on 5xx, until it succeeds ...
+	server := newHTTPServer(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusAccepted)
+	sender := server.sender(2)
+	s.Equal(nil, sender.Send(context.Background(), GELFMessage{Version: "1.1", ShortMessage: "retry"}))
+	s.Equal(3, len(server.requests()))
+	server.Close()
+
+	// 2. ... or until the retries are exhausted.
+	server = newHTTPServer(http.StatusInternalServerError)
+	sender = server.sender(1)
+	err := sender.Send(context.Background(), GELFMessage{Version: "1.1", ShortMessage: "retry"})
+	s.Equal("GELF HTTP input responded with status: 500 Internal Server Error", fmt.Sprint(err))
+	s.Equal(2, len(server.requests()))
+	server.Close()
+
+	// 3. Not retried on 4xx.
+	server = newHTTPServer(http.StatusUnauthorized)
+	sender = server.sender(3)
+	err = sender.Send(context.Background(), GELFMessage{Version: "1.1", ShortMessage: "retry"})
+	s.Equal("GELF HTTP input responded with status: 401 Unauthorized", fmt.Sprint(err))
+	s.Equal(1, len(server.requests()))
+	server.Close()
+
+	s.Equal(nil, sender.Close())
+}
+
+func (s httpSuite) TestHTTPStatusIsNotRepeated() {
+	// 1. The 4xx response is not repeated by the reconnect and the retries of the delivery ...
+	server := newHTTPServer(http.StatusBadRequest)
+	init := server.init(s, TransportHTTP)
+	init.GraylogStructured = true
+	init.GraylogRetries = 2
+	init.GraylogHTTPRetries = 2
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	err := g.SendGELF(levelInfoNum, "status", "bad request")
+	s.Equal(true, isDeliveryOp(err, DeliveryWrite), fmt.Sprint(err))
+	s.Equal(true, isHTTPStatusError(err))
+	s.Equal(1, len(server.requests()))
+	s.Equal(nil, g.Close())
+	server.Close()
+
+	// 2. ... and the 5xx response is retried GraylogHTTPRetries times, with the retry delays.
+	server = newHTTPServer(http.StatusServiceUnavailable)
+	init = server.init(s, TransportHTTP)
+	init.GraylogStructured = true
+	init.GraylogRetries = 2
+	init.GraylogRetryBackoff = 20 * time.Millisecond
+	init.GraylogHTTPRetries = 2
+
+	g = New(init)
+	g.CaptureOutput(testOutputFileName)
+	start := time.Now()
+	err = g.SendGELF(levelInfoNum, "status", "unavailable")
+	s.Equal("GELF write failed: GELF HTTP input responded with status: 503 Service Unavailable", fmt.Sprint(err))
+	s.Equal(3, len(server.requests()))
+	s.Equal(true, time.Since(start) >= 30*time.Millisecond, time.Since(start))
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+	server.Close()
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s httpSuite) TestCredentialsAreNotLogged() {
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = 1
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogHTTPPassword = "secret-password"
+	init.GraylogHTTPBearerToken = "secret-token"
+	init.GraylogHTTPHeaders = map[string]string{"Authorization": "Basic secret-basic", "X-API-Key": "secret-api-key"}
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "credentials")
+	g.SaveOutput()
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "could not connect to Graylog host"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "secret-password"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "secret-token"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "secret-basic"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "secret-api-key"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "X-API-Key:"+redactedText), g.GetOutput())
+	s.Equal("secret-password", g.GetInit().GraylogHTTPPassword)
+	s.Equal("secret-api-key", g.GetInit().GraylogHTTPHeaders["X-API-Key"])
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s httpSuite) TestInitHTTP() {
+	g := New(Init{GraylogProtocol: TransportHTTP, LogLevel: LevelDebug})
+	s.Equal(graylogHTTPPath, g.GetInit().GraylogHTTPPath)
+	s.Equal(graylogHTTPTimeout, g.GetInit().GraylogHTTPTimeout)
+
+	s.Equal(nil, TransportHTTP.validateTransport())
+	s.Equal(nil, TransportHTTPS.validateTransport())
+}
+
+// httpRequest holds the details of a request received by the httpServer.
+type httpRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// httpServer is a GELF HTTP input for tests,
+// which responds with the given status codes one after the other, repeating the last one.
+// The status 0 closes the connection without a response.
+type httpServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	received []httpRequest
+}
+
+func newHTTPServer(statuses ...int) *httpServer {
+	h := &httpServer{statuses: statuses}
+	h.Server = httptest.NewServer(h.handler())
+	return h
+}
+
+func newHTTPSServer(statuses ...int) *httpServer {
+	h := &httpServer{statuses: statuses}
+	h.Server = httptest.NewTLSServer(h.handler())
+	return h
+}
+
+func (h *httpServer) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		if payload, err := ioutil.ReadAll(r.Body); err == nil {
+			if payload, err = decompressGELF(payload); err == nil {
+				_ = json.Unmarshal(payload, &body)
+			}
+		}
+
+		h.mu.Lock()
+		h.received = append(h.received, httpRequest{method: r.Method, path: r.URL.Path, header: r.Header, body: body})
+		status := h.statuses[len(h.statuses)-1]
+		if len(h.received) <= len(h.statuses) {
+			status = h.statuses[len(h.received)-1]
+		}
+		h.mu.Unlock()
+
+		if status == 0 {
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(status)
+	})
+}
+
+func (h *httpServer) requests() []httpRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]httpRequest(nil), h.received...)
+}
+
+func (h *httpServer) init(s httpSuite, transport Transport) Init {
+	host, port, err := net.SplitHostPort(h.Listener.Addr().String())
+	s.Require().Equal(nil, err)
+
+	init := testInit
+	init.GraylogHost = host
+	init.GraylogPort, _ = strconv.Atoi(port)
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = transport
+	return init
+}
+
+func (h *httpServer) sender(retries int) *httpSender {
+	return &httpSender{client: h.Client(), url: h.URL + graylogHTTPPath, retries: retries}
+}
+
+func TestHTTPSuite(t *testing.T) {
+	suite.Run(t, new(httpSuite))
+}
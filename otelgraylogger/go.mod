@@ -0,0 +1,32 @@
+module github.com/takattila/graylogger/otelgraylogger
+
+go 1.23.0
+
+require (
+	github.com/stretchr/testify v1.11.1
+	github.com/takattila/graylogger v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel/sdk v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.38.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
+
+require (
+	github.com/bclicn/color v0.0.0-20180711051946-108f2023dc84 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tidwall/pretty v1.0.0 // indirect
+	go.opentelemetry.io/otel v1.38.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The replace directive is only used for local development, the users of this module
+// get the graylogger version required above. See the "Release order" section of the README.
+replace github.com/takattila/graylogger => ../
@@ -0,0 +1,42 @@
+// Package otelgraylogger correlates the GrayLogger log messages with the OpenTelemetry traces.
+package otelgraylogger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey is the name of the additional field holding the trace ID of the active span.
+	TraceIDKey = "trace_id"
+
+	// SpanIDKey is the name of the additional field holding the span ID of the active span.
+	SpanIDKey = "span_id"
+
+	// TraceFlagsKey is the name of the additional field holding the trace flags of the active span.
+	TraceFlagsKey = "trace_flags"
+)
+
+// Extractor is a graylogger.ContextExtractor, which pulls the trace ID, the span ID and the trace flags
+// of the active OpenTelemetry span out of the context, so they are added to the log line
+// and to the GELF message as _trace_id, _span_id and _trace_flags additional fields.
+// Nothing is added, if the context holds no valid span.
+// For example:
+//  g := graylogger.New(graylogger.Init{
+//      GraylogContextExtractor: otelgraylogger.Extractor,
+//      ...
+//  })
+//  g.InfoContext(ctx, "order", "A-1")
+func Extractor(ctx context.Context) []interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []interface{}{
+		TraceIDKey, sc.TraceID().String(),
+		SpanIDKey, sc.SpanID().String(),
+		TraceFlagsKey, sc.TraceFlags().String(),
+	}
+}
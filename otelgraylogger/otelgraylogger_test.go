@@ -0,0 +1,117 @@
+package otelgraylogger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/takattila/graylogger"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testOutputFileName = "test.out"
+
+type otelSuite struct {
+	suite.Suite
+}
+
+func (s *otelSuite) TestTraceCorrelation() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+	messages := s.serve(listener)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() {
+		_ = provider.Shutdown(context.Background())
+	}()
+
+	g := graylogger.New(graylogger.Init{
+		GraylogHost:             "127.0.0.1",
+		GraylogPort:             listener.Addr().(*net.TCPAddr).Port,
+		GraylogProvider:         "TestService",
+		GraylogProtocol:         graylogger.TransportTCP,
+		GraylogContextExtractor: Extractor,
+		LogEnv:                  "test",
+		LogLevel:                graylogger.LevelDebug,
+	})
+	g.CaptureOutput(testOutputFileName)
+
+	ctx, span := provider.Tracer("otelgraylogger").Start(context.Background(), "checkout")
+	g.InfoContext(ctx, "order", "A-1")
+	span.End()
+
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	spans := exporter.GetSpans()
+	s.Require().Equal(1, len(spans))
+	traceID := spans[0].SpanContext.TraceID().String()
+	spanID := spans[0].SpanContext.SpanID().String()
+
+	select {
+	case obj := <-messages:
+		s.Equal("order :: A-1", obj["short_message"])
+		s.Equal(traceID, obj["_trace_id"])
+		s.Equal(spanID, obj["_span_id"])
+		s.Equal("01", obj["_trace_flags"])
+	case <-time.After(5 * time.Second):
+		s.Fail("no GELF message received")
+	}
+
+	output, err := ioutil.ReadFile(testOutputFileName)
+	s.Equal(nil, err)
+	s.Equal(true, strings.Contains(string(output),
+		"[order :: A-1 :: trace_id :: "+traceID+" :: span_id :: "+spanID+" :: trace_flags :: 01]"))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s *otelSuite) TestExtractorWithoutSpan() {
+	s.Equal(0, len(Extractor(context.Background())))
+}
+
+// serve accepts one GELF TCP connection and decodes the received messages.
+func (s *otelSuite) serve(l net.Listener) chan map[string]interface{} {
+	messages := make(chan map[string]interface{}, 16)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		r := bufio.NewReader(conn)
+		for {
+			b, err := r.ReadBytes(0)
+			if err != nil {
+				return
+			}
+
+			obj := map[string]interface{}{}
+			if json.Unmarshal(bytes.Trim(b, "\n\x00"), &obj) == nil {
+				messages <- obj
+			}
+		}
+	}()
+	return messages
+}
+
+func TestOtelSuite(t *testing.T) {
+	suite.Run(t, new(otelSuite))
+}
@@ -1,35 +1,96 @@
 // graylogger was made to provide easy-to-analyze log messages.
-// For sending GELF messages it uses the: https://github.com/Devatoria/go-graylog package.
+// It sends the log messages into Graylog as GELF messages over UDP, TCP, TLS, HTTP or HTTPS.
 package graylogger
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/Devatoria/go-graylog"
 )
 
-var functions Functions
-
 // Init initializes the logger instance
 type Init struct {
 	GraylogHost     string        // Host name of the Graylog server
 	GraylogPort     int           // Port number of the Graylog server
 	GraylogProvider string        // The Name of the service which generates the log messages or sends logs into Graylog
-	GraylogProtocol Transport     // The name of the transport protocol: the way we send GELF messages (TransportTCP or TransportUDP)
+	GraylogProtocol Transport     // The name of the transport protocol: the way we send GELF messages (TransportTCP, TransportUDP, TransportTLS, TransportHTTP or TransportHTTPS)
 	GraylogTimeout  time.Duration // Optional, it declares the maximum amount of time a dial will wait for a connection to complete.
 
+	GraylogEndpoints        []Endpoint       // Optional, further Graylog inputs after GraylogHost:GraylogPort, e.g. the secondaries of EndpointFailover.
+	GraylogEndpointStrategy EndpointStrategy // Optional, how the endpoint of a GELF message is chosen: EndpointFailover (default), EndpointRoundRobin or EndpointRandom.
+	GraylogEndpointCooldown time.Duration    // Optional, how long an endpoint is skipped after its connect or GELF write has failed (default: 10s).
+
+	GraylogStructured bool // Optional, one GELF message is sent per log call, where the key/value pairs are additional fields (instead of one message per pair).
+
+	GraylogAsync     bool           // Optional, GELF messages are queued and sent by background workers instead of blocking the log call.
+	GraylogQueueSize int            // Optional, the capacity of the asynchronous GELF queue (default: 1024).
+	GraylogWorkers   int            // Optional, the number of background workers draining the asynchronous GELF queue (default: 1).
+	GraylogOverflow  OverflowPolicy // Optional, what happens when the asynchronous GELF queue is full: OverflowBlock (default), OverflowDropNewest or OverflowDropOldest.
+
+	GraylogTLSCAFile     string // Optional, PEM encoded CA bundle to verify the Graylog server with TransportTLS (default: system roots).
+	GraylogTLSCertFile   string // Optional, PEM encoded client certificate for mutual TLS.
+	GraylogTLSKeyFile    string // Optional, PEM encoded private key of the client certificate for mutual TLS.
+	GraylogTLSServerName string // Optional, overrides the server name used to verify the certificate of the Graylog server (default: GraylogHost).
+	GraylogTLSMinVersion uint16 // Optional, the minimum accepted TLS version, e.g. tls.VersionTLS13 (default: tls.VersionTLS12).
+
+	GraylogHTTPPath        string            // Optional, the URL path of the GELF HTTP input with TransportHTTP and TransportHTTPS (default: /gelf).
+	GraylogHTTPHeaders     map[string]string // Optional, custom headers added to every GELF HTTP request.
+	GraylogHTTPUsername    string            // Optional, username for basic authentication of the GELF HTTP input.
+	GraylogHTTPPassword    string            // Optional, password for basic authentication of the GELF HTTP input.
+	GraylogHTTPBearerToken string            // Optional, bearer token for the authentication of the GELF HTTP input.
+	GraylogHTTPTimeout     time.Duration     // Optional, the maximum amount of time a GELF HTTP request may take (default: 5s).
+	GraylogHTTPRetries     int               // Optional, how many times a GELF HTTP request is retried, if the server responds with 5xx status. The delay between the attempts is set by GraylogRetryBackoff and GraylogRetryMaxBackoff.
+
+	GraylogRetries         int           // Optional, how many times a failed connect or GELF write is retried with TransportTCP, TransportTLS, TransportHTTP and TransportHTTPS (default: no retries).
+	GraylogRetryBackoff    time.Duration // Optional, the delay before the first retry, which is doubled after every retry and randomized by jitter (default: 100ms).
+	GraylogRetryMaxBackoff time.Duration // Optional, the upper limit of the delay between two retries (default: 5s).
+	GraylogRetryMaxElapsed time.Duration // Optional, the maximum total time of retrying a connect or GELF write (default: unlimited, only GraylogRetries applies).
+
+	GraylogBreakerThreshold int           // Optional, after this many consecutive failures the connects and GELF writes are stopped for GraylogBreakerCooldown (default: disabled).
+	GraylogBreakerCooldown  time.Duration // Optional, how long the open circuit breaker rejects the connects and GELF writes, before a probe is let through (default: 30s).
+
+	GraylogSpoolDir            string        // Optional, the directory where the GELF messages are stored while the Graylog host is unreachable, they are replayed in order once it is reachable again (default: disabled).
+	GraylogSpoolSegmentSize    int           // Optional, the maximum size of a spool segment file in bytes (default: 1 MiB).
+	GraylogSpoolMaxSize        int           // Optional, the maximum size of all spool segment files in bytes, the oldest segments are dropped above it (default: 64 MiB).
+	GraylogSpoolMaxAge         time.Duration // Optional, the spool segments older than this are dropped (default: unlimited).
+	GraylogSpoolReplayInterval time.Duration // Optional, how often the replay of the spool is attempted (default: 1s).
+
+	GraylogUDPChunkSize   int              // Optional, the maximum size of a GELF UDP datagram in bytes, the larger messages are split into GELF chunks (default: 1420).
+	GraylogUDPChunkLimit  ChunkLimitPolicy // Optional, what happens with a GELF UDP message which does not fit into 128 chunks: ChunkLimitError (default) or ChunkLimitTruncate.
+	GraylogMaxMessageSize int              // Optional, the maximum size of an encoded GELF message in bytes, the larger messages are trimmed and marked by the _truncated field (default: unlimited).
+
+	GraylogCompression      Compression // Optional, the compression of the GELF UDP and HTTP payloads: CompressionNone (default), CompressionGzip or CompressionZlib. GELF TCP and TLS messages are never compressed.
+	GraylogCompressionLevel int         // Optional, the compression level from gzip.BestSpeed (1) to gzip.BestCompression (9), or gzip.HuffmanOnly (default: gzip.DefaultCompression, also set by 0). Use CompressionNone instead of gzip.NoCompression.
+
+	GraylogContextExtractor ContextExtractor // Optional, pulls key/value pairs (e.g. trace or user IDs) out of the context of the *Context logger functions into the log line and GELF additional fields.
+
+	GraylogStaticFields map[string]interface{} // Optional, additional fields added to every GELF message, e.g. the datacenter or the version of the service. The names must be valid GELF field names (the leading underscore is optional), _id is reserved.
+	GraylogGELFBuilder  GELFBuilder            // Optional, customizes every GELF message before it is sent, e.g. the short and full message or the additional fields (default: the messages are sent as they are built).
+
+	GraylogOnError func(err error, m GELFMessage) // Optional, it is called with every GELF message which could not be delivered, and with the *DeliveryError describing why. It must be safe for concurrent use. The messages it logs through the same GrayLogger are not sent to Graylog.
+
 	LogEnv   string   // Environment of the service: dev / test / prod
 	LogLevel LogLevel // It can be: debug, info, warning, error
 	LogColor bool     // Turn on/off colored log output. Color output can be useful during development, but it is recommended to turn it off in production environment.
+
+	LogMicroseconds bool // Optional, the time of the log lines is written with microsecond precision.
+	LogUTC          bool // Optional, the date and time of the log lines are written in UTC instead of the local time zone (FormatJSON writes the Unix time).
+
+	LogFormat  Format  // Optional, the format of the log lines: FormatText (default), FormatJSON, which writes one JSON object per line with the field names of the GELF messages, or FormatLogfmt.
+	LogEncoder Encoder // Optional, writes the log lines instead of the built-in Encoder of LogFormat, e.g. with a custom layout.
+
+	LogStaticFields bool // Optional, the GraylogStaticFields are written to the log lines as well.
 }
 
 type (
-	// Transport declares the the way we send GELF messages (TransportTCP or TransportUDP).
+	// Transport declares the the way we send GELF messages (TransportTCP, TransportUDP, TransportTLS, TransportHTTP or TransportHTTPS).
 	Transport string
 
 	// LogLevel defines the log levels that can be entered.
@@ -46,19 +107,39 @@ type Functions struct {
 }
 
 // GrayLogger holds the needed data to use the functions of this package.
-//  - initData -> the data with which the package was initialized
+//  - initData -> the data with which the package was initialized, it is not changed after New()
 //  - functions -> logger functions: Debug, Info, Warning, Error, Fatal
-//  - level -> log level converted to integer
+//  - level -> log level converted to integer, 0 if the output is discarded
+//  - levelName -> the log level set by Init.LogLevel or SetLogLevel()
 //  - fileName -> set by CaptureOutput() function, provides the filename where output can be saved
 //  - fileOpen ->  set by CaptureOutput() function, holds *os.File
-//  - graylog -> set by connect() function, it represents an established graylog connection
+//  - output -> the writer of the logger functions: StdOut or the file set by CaptureOutput()
+//  - conn -> the long-lived graylog connection, opened by connect() and released by Close()
+//  - queue -> the asynchronous GELF queue, set by New() if Init.GraylogAsync is enabled
+//  - tlsConfig -> set by New() if Init.GraylogProtocol is TransportTLS or TransportHTTPS
+//  - fields -> key/value pairs bound by With(), added to every log line and GELF message
+//  - static -> Init.GraylogStaticFields in the order of their names, set by New()
+//  - encoder -> writes the log lines, set by New() from Init.LogEncoder or Init.LogFormat
+//  - textEncoder -> writes the log lines if the encoder fails
+//  - mu -> guards level, levelName, fileName, fileOpen and output, which can be changed at runtime
 type GrayLogger struct {
-	initData  Init
-	functions Functions
-	level     int
-	fileName  string
-	fileOpen  *os.File
-	graylog   *graylog.Graylog
+	mu          sync.RWMutex
+	initData    Init
+	functions   Functions
+	level       int
+	levelName   LogLevel
+	fileName    string
+	fileOpen    *os.File
+	output      io.Writer
+	conn        *connection
+	queue       *asyncQueue
+	spool       *spool
+	breaker     *circuitBreaker
+	tlsConfig   *tls.Config
+	fields      []keyValue
+	static      []keyValue
+	encoder     Encoder
+	textEncoder TextEncoder
 }
 
 const (
@@ -70,6 +151,16 @@ const (
 	// and correction are either not necessary or are performed in the application
 	TransportUDP Transport = "udp"
 
+	// TransportTLS is the TransportTCP secured by TLS,
+	// the GELF messages are encrypted on their way to Graylog.
+	TransportTLS Transport = "tls"
+
+	// TransportHTTP posts GELF messages to the GELF HTTP input of Graylog.
+	TransportHTTP Transport = "http"
+
+	// TransportHTTPS is the TransportHTTP secured by TLS.
+	TransportHTTPS Transport = "https"
+
 	// LevelDebug logs everything
 	LevelDebug    LogLevel = "debug"
 	levelDebugNum int      = 7
@@ -93,13 +184,20 @@ const (
 
 // New configures the logging writers.
 func New(init Init) *GrayLogger {
-	logLevel := logLevelToInt(init.LogLevel)
-	init.setLogLevelFunctions(setLogLevelHandlers(logLevel, os.Stdout))
+	if init.LogFormat == "" {
+		init.LogFormat = FormatText
+	}
 
+	logLevel := logLevelToInt(init.LogLevel)
 	l := &GrayLogger{
-		initData:  init,
-		functions: functions,
-		level:     logLevel,
+		initData:    init,
+		functions:   newLogLevelFunctions(setLogLevelHandlers(logLevel, os.Stdout)),
+		output:      os.Stdout,
+		level:       logLevel,
+		levelName:   init.LogLevel,
+		conn:        &connection{},
+		encoder:     init.encoder(),
+		textEncoder: init.textEncoder(),
 	}
 
 	if err := l.initData.LogLevel.validateLogLevel(); err != nil && l.isSetGraylogObligatoryFields() {
@@ -110,10 +208,44 @@ func New(init Init) *GrayLogger {
 		l.Fatal(err)
 	}
 
+	if err := l.initData.LogFormat.validateFormat(); err != nil && l.isSetGraylogObligatoryFields() {
+		l.Fatal(err)
+	}
+
+	if err := validateStaticFields(l.initData.GraylogStaticFields); err != nil && l.isSetGraylogObligatoryFields() {
+		l.Fatal(err)
+	}
+	l.static = l.initData.staticFields()
+
 	if l.initData.GraylogTimeout == 0 {
 		l.initData.GraylogTimeout = graylogTimeout
 	}
 
+	l.initEndpoints()
+	l.initCompression()
+	l.initRetry()
+	l.initBreaker()
+
+	if l.initData.GraylogProtocol == TransportUDP {
+		l.initUDP()
+	}
+
+	if l.initData.GraylogProtocol == TransportTLS || l.initData.GraylogProtocol == TransportHTTPS {
+		l.initTLS()
+	}
+
+	if l.initData.GraylogProtocol == TransportHTTP || l.initData.GraylogProtocol == TransportHTTPS {
+		l.initHTTP()
+	}
+
+	if l.initData.GraylogSpoolDir != "" {
+		l.initSpool()
+	}
+
+	if l.initData.GraylogAsync {
+		l.initAsync()
+	}
+
 	return l
 }
 
@@ -126,8 +258,41 @@ func Tracking(depth int) TrackInfo {
 	return getTrackingInfo(depth)
 }
 
+// With returns a child logger, which adds the given key/value pairs to every log line
+// and to every GELF message as additional fields.
+// The child shares the Graylog connection of its parent, and inherits its configuration and output.
+func (g *GrayLogger) With(keysAndValues ...interface{}) *GrayLogger {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	fields := make([]keyValue, 0, len(g.fields)+(len(keysAndValues)+1)/2)
+	fields = append(fields, g.fields...)
+	fields = append(fields, keysAndValuesToPairs(keysAndValues)...)
+
+	return &GrayLogger{
+		initData:    g.initData,
+		functions:   g.functions.clone(),
+		level:       g.level,
+		levelName:   g.levelName,
+		fileName:    g.fileName,
+		output:      g.output,
+		conn:        g.conn,
+		queue:       g.queue,
+		spool:       g.spool,
+		breaker:     g.breaker,
+		tlsConfig:   g.tlsConfig,
+		fields:      fields,
+		static:      g.static,
+		encoder:     g.encoder,
+		textEncoder: g.textEncoder,
+	}
+}
+
 // DiscardOutput discards all level outputs and prevents sending messages to Graylog as well.
 func (g *GrayLogger) DiscardOutput() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	g.functions.Debug.SetOutput(ioutil.Discard)
 	g.functions.Info.SetOutput(ioutil.Discard)
 	g.functions.Warning.SetOutput(ioutil.Discard)
@@ -138,48 +303,66 @@ func (g *GrayLogger) DiscardOutput() {
 }
 
 // ResetLogger allows StdOut with the initialized log level and allows sending messages to Graylog as well.
+// The Graylog connection of the previous logger is released.
 func (g *GrayLogger) ResetLogger() *GrayLogger {
-	return New(g.initData)
+	_ = g.Close()
+	return New(g.GetInit())
 }
 
 // Debug writes Info to stdOut and sends GELF message to Graylog.
 func (g *GrayLogger) Debug(keysAndValues ...interface{}) {
-	g.functions.Debug.Println(g.formatLogLine(keysAndValues...))
-	g.SendGELF(levelDebugNum, keysAndValues...)
+	g.logTracked(context.Background(), levelDebugNum, getTrackingInfo(1), keysAndValues)
+}
+
+// DebugContext writes Debug to stdOut and sends GELF message to Graylog, until the context is done.
+func (g *GrayLogger) DebugContext(ctx context.Context, keysAndValues ...interface{}) {
+	g.logTracked(ctx, levelDebugNum, getTrackingInfo(1), keysAndValues)
 }
 
 // Info writes Info to stdOut and sends GELF message to Graylog.
 func (g *GrayLogger) Info(keysAndValues ...interface{}) {
-	g.functions.Info.Println(g.formatLogLine(keysAndValues...))
-	g.SendGELF(levelInfoNum, keysAndValues...)
+	g.logTracked(context.Background(), levelInfoNum, getTrackingInfo(1), keysAndValues)
+}
+
+// InfoContext writes Info to stdOut and sends GELF message to Graylog, until the context is done.
+func (g *GrayLogger) InfoContext(ctx context.Context, keysAndValues ...interface{}) {
+	g.logTracked(ctx, levelInfoNum, getTrackingInfo(1), keysAndValues)
 }
 
 // Warning writes Warning to stdOut and sends GELF message to Graylog.
 func (g *GrayLogger) Warning(keysAndValues ...interface{}) {
-	g.functions.Warning.Println(g.formatLogLine(keysAndValues...))
-	g.SendGELF(levelWarningNum, keysAndValues...)
+	g.logTracked(context.Background(), levelWarningNum, getTrackingInfo(1), keysAndValues)
+}
+
+// WarningContext writes Warning to stdOut and sends GELF message to Graylog, until the context is done.
+func (g *GrayLogger) WarningContext(ctx context.Context, keysAndValues ...interface{}) {
+	g.logTracked(ctx, levelWarningNum, getTrackingInfo(1), keysAndValues)
 }
 
 // LogWarningIfErr only writes Warning to stdOut, if err doesn't nil.
 // It also sends GELF message to Graylog, if it possible.
 func (g *GrayLogger) LogWarningIfErr(err error) {
 	if err != nil {
-		g.functions.Warning.Println(g.formatLogLine(getTrackingInfo(1).Function, err))
+		g.functions.Warning.Println(g.formatLogLine(levelWarningNum, getTrackingInfo(1).Function, err))
 		g.SendGELF(levelWarningNum, getTrackingInfo(1).Function, err)
 	}
 }
 
 // Error writes Error to stdOut and sends GELF message to Graylog.
 func (g *GrayLogger) Error(keysAndValues ...interface{}) {
-	g.functions.Error.Println(g.formatLogLine(keysAndValues...))
-	g.SendGELF(levelErrorNum, keysAndValues...)
+	g.logTracked(context.Background(), levelErrorNum, getTrackingInfo(1), keysAndValues)
+}
+
+// ErrorContext writes Error to stdOut and sends GELF message to Graylog, until the context is done.
+func (g *GrayLogger) ErrorContext(ctx context.Context, keysAndValues ...interface{}) {
+	g.logTracked(ctx, levelErrorNum, getTrackingInfo(1), keysAndValues)
 }
 
 // LogErrorIfErr only writes Error to stdOut, if err doesn't nil.
 // It also sends GELF message to Graylog, if it possible.
 func (g *GrayLogger) LogErrorIfErr(err error) {
 	if err != nil {
-		g.functions.Error.Println(g.formatLogLine(getTrackingInfo(1).Function, err))
+		g.functions.Error.Println(g.formatLogLine(levelErrorNum, getTrackingInfo(1).Function, err))
 		g.SendGELF(levelErrorNum, getTrackingInfo(1).Function, err)
 	}
 }
@@ -188,7 +371,7 @@ func (g *GrayLogger) LogErrorIfErr(err error) {
 // It also sends GELF message to Graylog, if it possible.
 func (g *GrayLogger) Fatal(err error) {
 	if err != nil {
-		g.functions.Fatal.Println(g.formatLogLine(getTrackingInfo(1).Function, err))
+		g.functions.Fatal.Println(g.formatLogLine(levelFatalNum, getTrackingInfo(1).Function, err))
 		g.SendGELF(levelFatalNum, getTrackingInfo(1).Function, err)
 		os.Exit(1)
 	}
@@ -197,14 +380,19 @@ func (g *GrayLogger) Fatal(err error) {
 // ReturnWithError writes Error to stdOut and returns with that error message at the same time.
 // It also sends GELF message to Graylog, if it possible.
 func (g *GrayLogger) ReturnWithError(keysAndValues ...interface{}) error {
-	g.functions.Error.Println(g.formatLogLine(keysAndValues...))
+	g.functions.Error.Println(g.formatLogLine(levelErrorNum, keysAndValues...))
 	g.SendGELF(levelErrorNum, keysAndValues...)
 	return fmt.Errorf(prettifyKeyVal(keyValToSlice(keysAndValues...)))
 }
 
-// GetInit returns with the initial logger data.
+// GetInit returns with the initial logger data, with the current log level.
 func (g *GrayLogger) GetInit() Init {
-	return g.initData
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	init := g.initData
+	init.LogLevel = g.levelName
+	return init
 }
 
 // IsAllowedOutput tells that StdOut is allowed or discarded on all log levels.
@@ -220,15 +408,44 @@ func (g *GrayLogger) IsAllowedOutput() bool {
 
 // GetLogLevel returns with the set log level (int, string).
 func (g *GrayLogger) GetLogLevel() (int, string) {
-	return logLevelToInt(g.GetInit().LogLevel), fmt.Sprint(g.GetInit().LogLevel)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return logLevelToInt(g.levelName), fmt.Sprint(g.levelName)
+}
+
+// SetLogLevel changes the log level of the logger at runtime.
+// The output is kept, it is written to StdOut or to the file set by CaptureOutput().
+func (g *GrayLogger) SetLogLevel(level LogLevel) error {
+	if err := level.validateLogLevel(); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.levelName = level
+	g.level = logLevelToInt(level)
+	setLogLevelHandlers(g.level, g.output).setOutput(g)
+
+	return nil
 }
 
 // CaptureOutput will redirect logger output to a given fileName.
 func (g *GrayLogger) CaptureOutput(fileName string) *GrayLogger {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.fileOpen != nil {
+		_ = g.fileOpen.Close()
+	}
+
 	g.fileName = fileName
 	_ = os.Remove(fileName)
 
 	f, _ := os.OpenFile(g.fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	g.fileOpen = f
+	g.output = f
 	setLogLevelHandlers(g.level, f).setOutput(g)
 
 	return g
@@ -237,13 +454,22 @@ func (g *GrayLogger) CaptureOutput(fileName string) *GrayLogger {
 // SaveOutput will close the file, what we set in CaptureOutput() function
 // and re-set the output of all logger functions .
 func (g *GrayLogger) SaveOutput() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	setLogLevelHandlers(g.level, os.Stdout).setOutput(g)
 	_ = g.fileOpen.Close()
-	g.initData.setLogLevelFunctions(setLogLevelHandlers(g.level, os.Stdout))
+	g.fileOpen = nil
+	g.output = os.Stdout
 }
 
 // GetOutput reads the file content what we set in the CaptureOutput() function.
 func (g *GrayLogger) GetOutput() string {
-	b, _ := ioutil.ReadFile(g.fileName)
+	g.mu.RLock()
+	fileName := g.fileName
+	g.mu.RUnlock()
+
+	b, _ := ioutil.ReadFile(fileName)
 	return string(b)
 }
 
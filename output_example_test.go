@@ -17,7 +17,7 @@ func ExampleNew() {
 
 	fmt.Println(g.GetInit())
 
-	// Output: { 0   100ms test debug true}
+	// Output: { 0   100ms [] failover 10s false false 0 0      0  map[]    0s 0 0 100ms 5s 0s 0 0s  0 0 0s 0s 0  0 none -1 <nil> map[] <nil> <nil> test debug true false false text <nil> false}
 }
 
 func ExampleTracking() {
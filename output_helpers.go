@@ -1,6 +1,7 @@
 package graylogger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -39,13 +40,17 @@ type logLevelHandlers struct {
 	fatal io.Writer
 }
 
-// setLogLevelFunctions initializes the output of the logger functions.
-func (i Init) setLogLevelFunctions(h logLevelHandlers) {
-	functions.Debug = log.New(h.debug, i.colorOut(colorGreen, "[DEBUG] "), log.Ldate|log.Ltime)
-	functions.Info = log.New(h.info, i.colorOut(colorBlue, "[INFO] "), log.Ldate|log.Ltime)
-	functions.Warning = log.New(h.warn, i.colorOut(colorPurple, "[WARNING] "), log.Ldate|log.Ltime)
-	functions.Error = log.New(h.error, i.colorOut(colorRed, "[ERROR] "), log.Ldate|log.Ltime)
-	functions.Fatal = log.New(h.fatal, i.colorOut(colorYellow, "[FATAL] "), log.Ldate|log.Ltime)
+// newLogLevelFunctions creates the logger functions of a GrayLogger instance with given writers.
+// Every call creates new loggers, so they don't share any state.
+// The log lines are written by the Encoder of the GrayLogger, so the logger functions have no prefix and flags.
+func newLogLevelFunctions(h logLevelHandlers) Functions {
+	return Functions{
+		Debug:   log.New(h.debug, "", 0),
+		Info:    log.New(h.info, "", 0),
+		Warning: log.New(h.warn, "", 0),
+		Error:   log.New(h.error, "", 0),
+		Fatal:   log.New(h.fatal, "", 0),
+	}
 }
 
 // setLogLevelHandlers decides whether the output of the logger functions should be discarded or not.
@@ -104,10 +109,43 @@ func (h logLevelHandlers) setOutput(g *GrayLogger) {
 	g.functions.Fatal.SetOutput(h.fatal)
 }
 
+// logLevel returns with the current log level of the logger converted to integer.
+func (g *GrayLogger) logLevel() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.level
+}
+
+// logTracked writes the log line of given log level to stdOut and sends GELF message to Graylog,
+// with given context and tracking information of the caller.
+// The fields are pulled out of the context once, so the log line and the GELF messages get the same fields.
+func (g *GrayLogger) logTracked(ctx context.Context, level int, tr TrackInfo, keysAndValues []interface{}) {
+	fields := g.boundFields(ctx)
+	g.levelFunction(level).Println(g.formatTrackedLogLine(level, tr, keysAndValues, fields))
+	g.sendGELFTracked(ctx, level, tr, keysAndValues, fields)
+}
+
+// levelFunction returns with the logger function of given log level.
+func (g *GrayLogger) levelFunction(level int) *log.Logger {
+	switch {
+	case level >= levelDebugNum:
+		return g.functions.Debug
+	case level >= levelInfoNum:
+		return g.functions.Info
+	case level >= levelWarningNum:
+		return g.functions.Warning
+	case level >= levelErrorNum:
+		return g.functions.Error
+	default:
+		return g.functions.Fatal
+	}
+}
+
 // validateTransport checks that given transport protocol is valid or not.
 func (t Transport) validateTransport() error {
 	switch t {
-	case TransportTCP, TransportUDP:
+	case TransportTCP, TransportUDP, TransportTLS, TransportHTTP, TransportHTTPS:
 		return nil
 	}
 	return fmt.Errorf("invalid transport protocol given: %s", t)
@@ -188,6 +226,20 @@ func getFuncName(depth int) string {
 	return fetchNameFromPath(me.Name())
 }
 
+// getTrackingInfoFromPC provides debug information about the function
+// of the given program counter, e.g. the caller recorded by log/slog.
+func getTrackingInfoFromPC(pc uintptr) TrackInfo {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return TrackInfo{File: "unknown", Line: "0", Function: "unknown"}
+	}
+	return TrackInfo{
+		File:     fetchNameFromPath(frame.File),
+		Line:     fmt.Sprintf("%d", frame.Line),
+		Function: fetchNameFromPath(frame.Function),
+	}
+}
+
 // fetchNameFromPath extracts the name of a function from a path.
 func fetchNameFromPath(fileName string) string {
 	for i := len(fileName) - 1; i > 0; i-- {
@@ -220,25 +272,47 @@ func (i *Init) colorOut(color, text string) string {
 	return c.White(text)
 }
 
-// formatLogLine provide a formatted log line.
+// formatLogLine provide a formatted log line of given log level.
 // For example:
 //  [DEBUG] 2020/01/21 12:53:09 [file: example.go line: 39 function: main.main] [Debug :: 10]
-func (g *GrayLogger) formatLogLine(keysAndValues ...interface{}) string {
-	tr := getTrackingInfo(2)
-	return fmt.Sprintf("%s [%s]",
-		g.initData.colorOut(colorGray, fmt.Sprintf("[file: %s line: %s function: %s]",
-			tr.File,
-			tr.Line,
-			tr.Function)),
-		prettifyKeyVal(keyValToSlice(keysAndValues...)))
+func (g *GrayLogger) formatLogLine(level int, keysAndValues ...interface{}) string {
+	return g.formatTrackedLogLine(level, getTrackingInfo(2), keysAndValues, g.boundFields(context.Background()))
+}
+
+// formatTrackedLogLine is the formatLogLine with given tracking information of the caller,
+// where fields are the fields pulled out of the context and the fields of the child logger (see boundFields).
+// The line is written by the Encoder of the logger (see Init.LogEncoder and Init.LogFormat).
+func (g *GrayLogger) formatTrackedLogLine(level int, tr TrackInfo, keysAndValues []interface{}, fields []keyValue) string {
+	pairs := append(keysAndValuesToPairs(keysAndValues), fields...)
+	if g.initData.LogStaticFields {
+		pairs = append(pairs, g.static...)
+	}
+	return g.encode(g.newRecord(level, tr, pairs))
 }
 
 // keyValToSlice returns with a string slice by given keysAndValues argument.
+// The pairs are made by the same rules as the GELF messages (see keysAndValuesToPairs).
 // For example:
 //  []string{"Debug", "information"}
-func keyValToSlice(keysAndValues ...interface{}) (sl []string) {
-	for _, v := range keysAndValues {
-		sl = append(sl, fmt.Sprintf("%+v", v))
+func keyValToSlice(keysAndValues ...interface{}) []string {
+	return pairsToSlice(keysAndValuesToPairs(keysAndValues))
+}
+
+// pairsToSlice returns with a string slice by given key : value pairs.
+func pairsToSlice(pairs []keyValue) (sl []string) {
+	for _, kv := range pairs {
+		sl = append(sl, kv.key, fmt.Sprintf("%+v", kv.value))
 	}
 	return sl
 }
+
+// clone creates new logger functions with the same outputs, prefixes and flags.
+func (f Functions) clone() Functions {
+	return Functions{
+		Debug:   log.New(f.Debug.Writer(), f.Debug.Prefix(), f.Debug.Flags()),
+		Info:    log.New(f.Info.Writer(), f.Info.Prefix(), f.Info.Flags()),
+		Warning: log.New(f.Warning.Writer(), f.Warning.Prefix(), f.Warning.Flags()),
+		Error:   log.New(f.Error.Writer(), f.Error.Prefix(), f.Error.Flags()),
+		Fatal:   log.New(f.Fatal.Writer(), f.Fatal.Prefix(), f.Fatal.Flags()),
+	}
+}
@@ -3,7 +3,10 @@ package graylogger
 import (
 	"fmt"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -99,6 +102,54 @@ func (s outputHelpersSuite) TestGetFuncName() {
 	s.Equal("unknown", unknown)
 }
 
+func (s outputHelpersSuite) TestGetTrackingInfoFromPC() {
+	pc, _, _, _ := runtime.Caller(0)
+	tr := getTrackingInfoFromPC(pc)
+	s.Equal("output_helpers_test.go", tr.File)
+	s.NotEqual("0", tr.Line)
+	s.Equal("graylogger.outputHelpersSuite.TestGetTrackingInfoFromPC", tr.Function)
+
+	tr = getTrackingInfoFromPC(0)
+	s.Equal(TrackInfo{File: "unknown", Line: "0", Function: "unknown"}, tr)
+}
+
+func (s outputHelpersSuite) TestLevelFunction() {
+	g := New(testInit)
+	s.Equal(g.functions.Debug, g.levelFunction(levelDebugNum))
+	s.Equal(g.functions.Info, g.levelFunction(levelInfoNum))
+	s.Equal(g.functions.Warning, g.levelFunction(5))
+	s.Equal(g.functions.Warning, g.levelFunction(levelWarningNum))
+	s.Equal(g.functions.Error, g.levelFunction(levelErrorNum))
+	s.Equal(g.functions.Fatal, g.levelFunction(levelFatalNum))
+}
+
+func (s outputHelpersSuite) TestLogFlags() {
+	s.Equal(TextEncoder{}, Init{}.encoder())
+	s.Equal(TextEncoder{Microseconds: true}, Init{LogMicroseconds: true}.encoder())
+	s.Equal(TextEncoder{Microseconds: true, UTC: true}, Init{LogMicroseconds: true, LogUTC: true}.encoder())
+
+	init := testInit
+	init.LogMicroseconds = true
+	init.LogUTC = true
+
+	g := New(init)
+	s.Equal(TextEncoder{Microseconds: true, UTC: true}, g.encoder)
+	s.Equal(TextEncoder{Microseconds: true, UTC: true}, g.textEncoder)
+	s.Equal(0, g.functions.Info.Flags())
+
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "microseconds")
+	g.SaveOutput()
+
+	output := g.GetOutput()
+	s.Equal(true, strings.Contains(output, "[INFO] "))
+	s.Equal(true, strings.Contains(output, time.Now().UTC().Format("2006/01/02")+" "))
+	s.Regexp(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d{6} `, output)
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
 func (s outputHelpersSuite) TestFetchNameFromPath() {
 	path := "/path/fo/testFuncName"
 
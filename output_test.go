@@ -2,6 +2,7 @@ package graylogger
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -100,6 +101,146 @@ func (s outputSuite) TestNewValidateTransportFatal() {
 		"Fatal function was not called")
 }
 
+func (s outputSuite) TestIndependentLoggers() {
+	debugInit := testInit
+	debugInit.LogLevel = LevelDebug
+
+	errorInit := testInit
+	errorInit.LogLevel = LevelError
+	errorInit.LogColor = true
+
+	debugLogger := New(debugInit)
+	errorLogger := New(errorInit)
+	s.NotEqual(debugLogger.functions.Debug, errorLogger.functions.Debug)
+
+	debugFileName := "test_debug.out"
+	errorFileName := "test_error.out"
+
+	debugLogger.CaptureOutput(debugFileName)
+	errorLogger.CaptureOutput(errorFileName)
+
+	// Re-creating and resetting other loggers must not affect the existing ones.
+	New(testInit).ResetLogger().DiscardOutput()
+
+	debugLogger.Debug("debug", "logger")
+	errorLogger.Debug("error", "logger")
+	errorLogger.Error("error", "logger")
+
+	debugLogger.SaveOutput()
+
+	// Saving the output of one logger doesn't affect the other one.
+	errorLogger.Error("error", "still captured")
+	errorLogger.SaveOutput()
+
+	// After SaveOutput, the file is not written anymore.
+	debugLogger.Debug("debug", "stdout")
+
+	s.Equal(true, strings.Contains(debugLogger.GetOutput(), "[DEBUG] "))
+	s.Equal(true, strings.Contains(debugLogger.GetOutput(), "debug :: logger"))
+	s.Equal(false, strings.Contains(debugLogger.GetOutput(), "debug :: stdout"))
+
+	s.Equal(false, strings.Contains(errorLogger.GetOutput(), "[DEBUG] "))
+	s.Equal(true, strings.Contains(errorLogger.GetOutput(), "\x1b[0;91m[ERROR] \x1b[0m"))
+	s.Equal(true, strings.Contains(errorLogger.GetOutput(), "error :: still captured"))
+
+	s.Equal(nil, os.Remove(debugFileName))
+	s.Equal(nil, os.Remove(errorFileName))
+}
+
+func (s outputSuite) TestSetLogLevel() {
+	g := New(testInit)
+
+	g.CaptureOutput(testOutputFileName)
+	g.Debug("before", "set log level")
+	s.Equal(nil, g.SetLogLevel(LevelError))
+	g.Debug("after", "set log level")
+	g.Error("after", "set log level")
+	g.SaveOutput()
+
+	levelNum, levelString := g.GetLogLevel()
+	s.Equal(levelErrorNum, levelNum)
+	s.Equal("error", levelString)
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "[DEBUG] "))
+	s.Equal(true, strings.Contains(g.GetOutput(), "before :: set log level"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "[DEBUG] 0"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[ERROR] "))
+	s.Equal(1, strings.Count(g.GetOutput(), "after :: set log level"))
+
+	err := g.SetLogLevel("bad_log_level")
+	s.Equal("invalid logging level given: bad_log_level", fmt.Sprint(err))
+	s.Equal(LevelError, g.GetInit().LogLevel)
+	s.Equal(LevelDebug, g.initData.LogLevel)
+
+	g.DiscardOutput()
+	levelNum, levelString = g.GetLogLevel()
+	s.Equal(levelErrorNum, levelNum)
+	s.Equal("error", levelString)
+	s.Equal(LevelError, g.ResetLogger().GetInit().LogLevel)
+
+	resetTest(s)
+}
+
+// TestConcurrentUse hammers one logger from many goroutines,
+// it is meant to be run with the race detector: go test -race
+func (s outputSuite) TestConcurrentUse() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogAsync = true
+	init.GraylogWorkers = 2
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	levels := []LogLevel{LevelDebug, LevelInfo, LevelWarning, LevelError}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				g.Debug("goroutine", i, "iteration", j)
+				g.Info("goroutine", i, "iteration", j)
+				g.Warning("goroutine", i)
+				g.Error("goroutine", i)
+				g.LogErrorIfErr(fmt.Errorf("error %d", j))
+				_ = g.ReturnWithError("goroutine", i)
+				_ = g.IsAllowedOutput()
+				_ = g.GetInit()
+				_, _ = g.GetLogLevel()
+				_ = g.GetOutput()
+
+				switch j % 10 {
+				case 0:
+					_ = g.SetLogLevel(levels[(i+j)%len(levels)])
+				case 3:
+					_ = g.Close()
+				case 5:
+					_ = g.Flush(context.Background())
+				case 7:
+					g.SendGELF(levelInfoNum, "direct", i)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s.Equal(nil, g.Flush(context.Background()))
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+	g.DiscardOutput()
+	s.Equal(false, g.IsAllowedOutput())
+
+	resetTest(s)
+}
+
 func (s outputSuite) TestTracking() {
 	t := Tracking(1)
 	s.Equal("output_test.go", t.File)
@@ -117,6 +258,39 @@ func (s outputSuite) TestTracking() {
 	}()
 }
 
+func (s outputSuite) TestWith() {
+	g := New(testInit)
+	g.CaptureOutput(testOutputFileName)
+
+	child := g.With("request_id", 42)
+	grandChild := child.With("tenant", "acme")
+
+	g.Info("parent", "line")
+	child.Info("child", "line")
+	grandChild.Info("dangling")
+
+	// The child has its own level ...
+	s.Equal(nil, child.SetLogLevel(LevelError))
+	child.Info("child", "filtered")
+	child.Error("child", "error")
+	g.Info("parent", "not filtered")
+	g.SaveOutput()
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "[parent :: line]"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[child :: line :: request_id :: 42]"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[!BADKEY :: dangling :: request_id :: 42 :: tenant :: acme]"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[parent :: not filtered]"))
+	s.Equal(false, strings.Contains(g.GetOutput(), "child :: filtered"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[child :: error :: request_id :: 42]"))
+
+	// ... and the bound fields of the child don't leak into the parent.
+	s.Equal(0, len(g.fields))
+	s.Equal(1, len(child.fields))
+	s.Equal(2, len(grandChild.fields))
+
+	resetTest(s)
+}
+
 func (s outputSuite) TestDiscardOutput() {
 	g := New(testInit)
 
@@ -189,6 +363,22 @@ func (s outputSuite) TestInfo() {
 	resetTest(s)
 }
 
+func (s outputSuite) TestKeysAndValues() {
+	g := New(testInit)
+
+	g.CaptureOutput(testOutputFileName)
+	g.Info("c", 1, "b", 2, "a", 3)
+	g.Info("single message")
+	g.Info("key", "value", "dangling")
+	g.SaveOutput()
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "[c :: 1 :: b :: 2 :: a :: 3]"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[!BADKEY :: single message]"))
+	s.Equal(true, strings.Contains(g.GetOutput(), "[key :: value :: !BADKEY :: dangling]"))
+
+	resetTest(s)
+}
+
 func (s outputSuite) TestWarning() {
 	g := New(testInit)
 
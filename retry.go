@@ -0,0 +1,126 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// graylogRetryBackoff declares the default delay before the first retry of a GELF delivery.
+	graylogRetryBackoff = 100 * time.Millisecond
+
+	// graylogRetryMaxBackoff declares the default upper limit of the delay between two retries.
+	graylogRetryMaxBackoff = 5 * time.Second
+)
+
+// randomSource randomizes the retry delays, so the loggers of a fleet do not retry in lockstep
+// after a Graylog restart, and chooses the endpoints of EndpointRandom.
+// It is seeded explicitly, because the global source is not seeded with Go 1.13.
+var randomSource = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// initRetry sets the defaults of the retries of the GELF delivery.
+func (g *GrayLogger) initRetry() {
+	if g.initData.GraylogRetryBackoff == 0 {
+		g.initData.GraylogRetryBackoff = graylogRetryBackoff
+	}
+
+	if g.initData.GraylogRetryMaxBackoff == 0 {
+		g.initData.GraylogRetryMaxBackoff = graylogRetryMaxBackoff
+	}
+
+	if err := validateRetries(g.initData.GraylogRetries); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	for _, d := range []time.Duration{g.initData.GraylogRetryBackoff, g.initData.GraylogRetryMaxBackoff, g.initData.GraylogRetryMaxElapsed} {
+		if err := validateRetryDuration(d); err != nil && g.isSetGraylogObligatoryFields() {
+			g.Fatal(err)
+		}
+	}
+}
+
+// retry calls f until it succeeds, Init.GraylogRetries is exhausted, Init.GraylogRetryMaxElapsed is elapsed
+// or the context is done, and returns with the last error.
+// The delay between the attempts is doubled after every retry, up to Init.GraylogRetryMaxBackoff.
+// Only TransportTCP, TransportTLS, TransportHTTP and TransportHTTPS are retried,
+// and the encoding errors and the calls rejected by the open circuit breaker are never retried, because they would fail again.
+// The responses of the GELF HTTP input are retried by Init.GraylogHTTPRetries only.
+func (g *GrayLogger) retry(ctx context.Context, f func() error) error {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || !g.retryable() || attempt >= g.initData.GraylogRetries || isDeliveryOp(err, DeliveryEncode) || errors.Is(err, errBreakerOpen) || isHTTPStatusError(err) {
+			return err
+		}
+
+		delay := g.retryDelay(attempt)
+		if max := g.initData.GraylogRetryMaxElapsed; max > 0 && time.Since(start)+delay > max {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryable checks that the GELF delivery is retried by the transport protocol or not.
+func (g *GrayLogger) retryable() bool {
+	switch g.initData.GraylogProtocol {
+	case TransportTCP, TransportTLS, TransportHTTP, TransportHTTPS:
+		return g.initData.GraylogRetries > 0
+	}
+	return false
+}
+
+// retryDelay returns with the delay before the retry after given failed attempt (counted from zero):
+// the exponential backoff limited by Init.GraylogRetryMaxBackoff, randomized between its half and its full value.
+// For example, with the default 100ms backoff:
+//  0 -> 50ms..100ms, 1 -> 100ms..200ms, 2 -> 200ms..400ms, ... 6 -> 2.5s..5s
+func (g *GrayLogger) retryDelay(attempt int) time.Duration {
+	delay := g.initData.GraylogRetryBackoff
+	for i := 0; i < attempt && delay < g.initData.GraylogRetryMaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > g.initData.GraylogRetryMaxBackoff {
+		delay = g.initData.GraylogRetryMaxBackoff
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	randomSource.Lock()
+	defer randomSource.Unlock()
+
+	return half + time.Duration(randomSource.Int63n(int64(delay-half)+1))
+}
+
+// validateRetries checks that given retry count is valid or not.
+func validateRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("invalid retry count given: %d", retries)
+	}
+	return nil
+}
+
+// validateRetryDuration checks that given retry backoff or time limit is valid or not.
+func validateRetryDuration(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("invalid retry duration given: %s", d)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type retrySuite struct {
+	suite.Suite
+}
+
+func (s retrySuite) TestRetryConnect() {
+	port := s.freePort()
+
+	started := make(chan *tcpServer, 1)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		s.Require().Equal(nil, err)
+		started <- newListenerServer(graylogSuite{s.Suite}, l)
+	}()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = port
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogRetries = 20
+	init.GraylogRetryBackoff = 20 * time.Millisecond
+	init.GraylogRetryMaxBackoff = 50 * time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "test", "retry"))
+	g.SaveOutput()
+
+	server := <-started
+	defer server.close()
+	s.Equal("test :: retry", server.message()["short_message"])
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s retrySuite) TestRetryWrite() {
+	// The first write and its resend on a new connection fail, the retry succeeds.
+	server := newHTTPServer(0, 0, http.StatusAccepted)
+	defer server.Close()
+
+	init := server.init(httpSuite{s.Suite}, TransportHTTP)
+	init.GraylogRetries = 1
+	init.GraylogRetryBackoff = time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	s.Equal(nil, g.SendGELF(levelInfoNum, "test", "retry"))
+	g.SaveOutput()
+
+	s.Equal(3, len(server.requests()))
+	s.Equal(nil, g.LastError())
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s retrySuite) TestRetryGivesUp() {
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = s.freePort()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogRetries = 1000
+	init.GraylogRetryBackoff = 10 * time.Millisecond
+	init.GraylogRetryMaxElapsed = 100 * time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	start := time.Now()
+	err := g.SendGELF(levelInfoNum, "test", "retry")
+	g.SaveOutput()
+
+	s.Equal(true, isDeliveryOp(err, DeliveryConnect))
+	s.Equal(true, time.Since(start) < time.Second)
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s retrySuite) TestRetry() {
+	failure := errors.New("failure")
+	g := New(Init{GraylogProtocol: TransportTCP, GraylogRetries: 3, GraylogRetryBackoff: time.Millisecond, LogLevel: LevelDebug})
+
+	// 1. Retried until Init.GraylogRetries is exhausted ...
+	attempts := 0
+	s.Equal(failure, g.retry(context.Background(), func() error {
+		attempts++
+		return failure
+	}))
+	s.Equal(4, attempts)
+
+	// 2. ... or until it succeeds ...
+	attempts = 0
+	s.Equal(nil, g.retry(context.Background(), func() error {
+		attempts++
+		if attempts < 2 {
+			return failure
+		}
+		return nil
+	}))
+	s.Equal(2, attempts)
+
+	// 3. ... the encoding errors are not retried ...
+	attempts = 0
+	encodeErr := deliveryError(DeliveryEncode, failure)
+	s.Equal(encodeErr, g.retry(context.Background(), func() error {
+		attempts++
+		return encodeErr
+	}))
+	s.Equal(1, attempts)
+
+	// 4. ... and the retry is given up, when the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	s.Equal(failure, g.retry(ctx, func() error {
+		attempts++
+		return failure
+	}))
+	s.Equal(1, attempts)
+
+	// 5. UDP is not retried.
+	g = New(Init{GraylogProtocol: TransportUDP, GraylogRetries: 3, LogLevel: LevelDebug})
+	attempts = 0
+	s.Equal(failure, g.retry(context.Background(), func() error {
+		attempts++
+		return failure
+	}))
+	s.Equal(1, attempts)
+}
+
+func (s retrySuite) TestRetryDelay() {
+	g := New(Init{GraylogProtocol: TransportTCP, GraylogRetries: 10, LogLevel: LevelDebug})
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond} {
+		delay := g.retryDelay(attempt)
+		s.Equal(true, delay >= max/2, delay)
+		s.Equal(true, delay <= max, delay)
+	}
+
+	s.Equal(true, g.retryDelay(100) <= graylogRetryMaxBackoff)
+	s.Equal(true, g.retryDelay(100) >= graylogRetryMaxBackoff/2)
+}
+
+func (s retrySuite) TestInitRetry() {
+	g := New(Init{GraylogProtocol: TransportTCP, LogLevel: LevelDebug})
+	s.Equal(0, g.GetInit().GraylogRetries)
+	s.Equal(graylogRetryBackoff, g.GetInit().GraylogRetryBackoff)
+	s.Equal(graylogRetryMaxBackoff, g.GetInit().GraylogRetryMaxBackoff)
+	s.Equal(time.Duration(0), g.GetInit().GraylogRetryMaxElapsed)
+	s.Equal(false, g.retryable())
+
+	s.Equal(nil, validateRetries(0))
+	s.Equal("invalid retry count given: -1", fmt.Sprint(validateRetries(-1)))
+	s.Equal(nil, validateRetryDuration(time.Second))
+	s.Equal("invalid retry duration given: -1s", fmt.Sprint(validateRetryDuration(-time.Second)))
+}
+
+// freePort returns with a TCP port, where nobody listens.
+func (s retrySuite) freePort() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	s.Require().Equal(nil, l.Close())
+	return port
+}
+
+func TestRetrySuite(t *testing.T) {
+	suite.Run(t, new(retrySuite))
+}
@@ -0,0 +1,113 @@
+//go:build go1.21
+// +build go1.21
+
+package graylogger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a log/slog Handler, which routes the records through a GrayLogger:
+// they are written to stdOut and sent to Graylog the same way as by the logger functions.
+//  - the slog levels are mapped to the syslog levels of the GrayLogger
+//  - the message is the first key/value pair under the "msg" key
+//  - the attributes are key/value pairs, the keys of grouped attributes are joined by dots
+type SlogHandler struct {
+	g      *GrayLogger
+	attrs  []interface{}
+	prefix string
+}
+
+// NewSlogHandler creates a log/slog Handler backed by the given GrayLogger.
+// For example:
+//  logger := slog.New(graylogger.NewSlogHandler(g))
+func NewSlogHandler(g *GrayLogger) *SlogHandler {
+	return &SlogHandler{g: g}
+}
+
+// Enabled tells that the records of given slog level are logged or not.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return slogLevelToInt(level) <= h.g.logLevel()
+}
+
+// Handle writes the record to stdOut and sends it to Graylog,
+// the same way as the *Context logger functions of the GrayLogger with given context.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	keysAndValues := make([]interface{}, 0, 2+len(h.attrs)+2*r.NumAttrs())
+	keysAndValues = append(keysAndValues, slog.MessageKey, r.Message)
+	keysAndValues = append(keysAndValues, h.attrs...)
+
+	r.Attrs(func(a slog.Attr) bool {
+		keysAndValues = appendSlogAttr(keysAndValues, h.prefix, a)
+		return true
+	})
+
+	h.g.logTracked(ctx, slogLevelToInt(r.Level), getTrackingInfoFromPC(r.PC), keysAndValues)
+	return nil
+}
+
+// WithAttrs returns a derived handler, whose records carry the given attributes as well.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	derived := *h
+	derived.attrs = append([]interface{}(nil), h.attrs...)
+	for _, a := range attrs {
+		derived.attrs = appendSlogAttr(derived.attrs, h.prefix, a)
+	}
+	return &derived
+}
+
+// WithGroup returns a derived handler, whose further attributes are grouped under the given name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	derived := *h
+	derived.prefix = h.prefix + name + "."
+	return &derived
+}
+
+// appendSlogAttr flattens a slog attribute into key/value pairs,
+// where the keys of the grouped attributes are prefixed by the name of their groups.
+// For example:
+//  slog.Group("request", slog.String("id", "42")) -> "request.id", "42"
+func appendSlogAttr(keysAndValues []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			keysAndValues = appendSlogAttr(keysAndValues, prefix, ga)
+		}
+		return keysAndValues
+	}
+
+	return append(keysAndValues, prefix+a.Key, a.Value.Any())
+}
+
+// slogLevelToInt converts a slog level to the integer value of the syslog level.
+// For example:
+//  slog.LevelDebug -> 7
+//  slog.LevelWarn -> 4
+func slogLevelToInt(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return levelDebugNum
+	case level < slog.LevelWarn:
+		return levelInfoNum
+	case level < slog.LevelError:
+		return levelWarningNum
+	default:
+		return levelErrorNum
+	}
+}
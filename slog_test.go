@@ -0,0 +1,104 @@
+//go:build go1.21
+// +build go1.21
+
+package graylogger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type slogSuite struct {
+	suite.Suite
+}
+
+func (s slogSuite) TestSlogHandler() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStructured = true
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+
+	logger := slog.New(NewSlogHandler(g)).
+		With("service", "checkout").
+		WithGroup("request").
+		With("id", 42)
+
+	logger.Warn("payment failed", "amount", 9.5, slog.Group("user", "name", "gopher"))
+	g.SaveOutput()
+
+	output := g.GetOutput()
+	s.Equal(true, strings.Contains(output, "[WARNING] "))
+	s.Equal(true, strings.Contains(output, "file: slog_test.go"))
+	s.Equal(true, strings.Contains(output, "function: graylogger.slogSuite.TestSlogHandler"))
+	s.Equal(true, strings.Contains(output, "[msg :: payment failed :: service :: checkout :: request.id :: 42 :: request.amount :: 9.5 :: request.user.name :: gopher]"))
+
+	obj := server.message()
+	s.Equal("payment failed", obj["_msg"])
+	s.Equal("checkout", obj["_service"])
+	s.Equal("42", obj["_request.id"])
+	s.Equal("9.5", obj["_request.amount"])
+	s.Equal("gopher", obj["_request.user.name"])
+	s.Equal("warning", obj["_log_level"])
+	s.Equal("slog_test.go", obj["_track_file"])
+	s.Equal(float64(4), obj["level"])
+
+	s.Equal(nil, g.Close())
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s slogSuite) TestEnabled() {
+	init := testInit
+	init.LogLevel = LevelWarning
+	h := NewSlogHandler(New(init))
+
+	s.Equal(false, h.Enabled(context.Background(), slog.LevelDebug))
+	s.Equal(false, h.Enabled(context.Background(), slog.LevelInfo))
+	s.Equal(true, h.Enabled(context.Background(), slog.LevelWarn))
+	s.Equal(true, h.Enabled(context.Background(), slog.LevelError))
+}
+
+func (s slogSuite) TestWithoutAttrsAndGroup() {
+	h := NewSlogHandler(New(testInit))
+
+	s.Equal(h, h.WithAttrs(nil))
+	s.Equal(h, h.WithGroup(""))
+}
+
+func (s slogSuite) TestAppendSlogAttr() {
+	kv := appendSlogAttr(nil, "", slog.Attr{})
+	s.Equal(0, len(kv))
+
+	kv = appendSlogAttr(nil, "", slog.Group("", slog.Int("inline", 1)))
+	s.Equal([]interface{}{"inline", int64(1)}, kv)
+
+	kv = appendSlogAttr(nil, "a.", slog.Group("b", slog.String("c", "d")))
+	s.Equal([]interface{}{"a.b.c", "d"}, kv)
+}
+
+func (s slogSuite) TestSlogLevelToInt() {
+	s.Equal(levelDebugNum, slogLevelToInt(slog.LevelDebug))
+	s.Equal(levelDebugNum, slogLevelToInt(slog.LevelDebug-4))
+	s.Equal(levelInfoNum, slogLevelToInt(slog.LevelInfo))
+	s.Equal(levelInfoNum, slogLevelToInt(slog.LevelInfo+2))
+	s.Equal(levelWarningNum, slogLevelToInt(slog.LevelWarn))
+	s.Equal(levelErrorNum, slogLevelToInt(slog.LevelError))
+	s.Equal(levelErrorNum, slogLevelToInt(slog.LevelError+4))
+}
+
+func TestSlogSuite(t *testing.T) {
+	suite.Run(t, new(slogSuite))
+}
@@ -0,0 +1,484 @@
+package graylogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// graylogSpoolSegmentSize declares the default maximum size of a spool segment file.
+	graylogSpoolSegmentSize = 1 << 20
+
+	// graylogSpoolMaxSize declares the default maximum size of all spool segment files.
+	graylogSpoolMaxSize = 64 << 20
+
+	// graylogSpoolReplayInterval declares the default period of the replay attempts of the spool.
+	graylogSpoolReplayInterval = time.Second
+
+	// spoolSegmentExt is the extension of the spool segment files.
+	spoolSegmentExt = ".gelf"
+)
+
+var (
+	// errSpoolFull is the error of the spooled GELF messages dropped by Init.GraylogSpoolMaxSize.
+	errSpoolFull = errors.New("GELF spool is full")
+
+	// errSpoolExpired is the error of the spooled GELF messages dropped by Init.GraylogSpoolMaxAge.
+	errSpoolExpired = errors.New("GELF spool segment is expired")
+)
+
+// spoolSegment is a file of the spool, which holds newline delimited JSON encoded GELF messages.
+// The segments are named after their sequence number, so they are replayed in the order of their creation.
+// For example:
+//  00000000000000000042.gelf
+type spoolSegment struct {
+	seq     uint64
+	size    int
+	modTime time.Time
+}
+
+// spool stores the GELF messages, which could not be delivered, in segment files of a directory,
+// and replays them in order by a background goroutine once the Graylog host is reachable again.
+//  - segments -> the segment files from the oldest to the newest
+//  - file -> the newest segment opened for appending, nil if it is sealed
+//  - replaying -> the segment being replayed, which is never dropped by the size limit
+//  - running -> the replayer is started by the first message and stopped by stop()
+type spool struct {
+	dir         string
+	segmentSize int
+	maxSize     int
+	maxAge      time.Duration
+	interval    time.Duration
+	write       func(context.Context, GELFMessage) error
+	report      func(error, GELFMessage)
+
+	mu        sync.Mutex
+	segments  []*spoolSegment
+	file      *os.File
+	nextSeq   uint64
+	replaying *spoolSegment
+	running   bool
+	stopping  chan struct{}
+	stopped   chan struct{}
+}
+
+// initSpool sets the defaults of the spool and opens its directory.
+func (g *GrayLogger) initSpool() {
+	if g.initData.GraylogSpoolSegmentSize == 0 {
+		g.initData.GraylogSpoolSegmentSize = graylogSpoolSegmentSize
+	}
+
+	if g.initData.GraylogSpoolMaxSize == 0 {
+		g.initData.GraylogSpoolMaxSize = graylogSpoolMaxSize
+	}
+
+	if g.initData.GraylogSpoolReplayInterval == 0 {
+		g.initData.GraylogSpoolReplayInterval = graylogSpoolReplayInterval
+	}
+
+	if err := validateSpoolSize(g.initData.GraylogSpoolSegmentSize, g.initData.GraylogSpoolMaxSize); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	s, err := openSpool(g.initData, g.write, g.reportError)
+	if err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+	g.spool = s
+}
+
+// openSpool creates the spool directory if it does not exist yet, and loads the segments left by the previous process.
+// If there are such segments, their replay is started.
+func openSpool(init Init, write func(context.Context, GELFMessage) error, report func(error, GELFMessage)) (*spool, error) {
+	if err := os.MkdirAll(init.GraylogSpoolDir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create GELF spool directory: %s", err)
+	}
+
+	files, err := ioutil.ReadDir(init.GraylogSpoolDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read GELF spool directory: %s", err)
+	}
+
+	s := &spool{
+		dir:         init.GraylogSpoolDir,
+		segmentSize: init.GraylogSpoolSegmentSize,
+		maxSize:     init.GraylogSpoolMaxSize,
+		maxAge:      init.GraylogSpoolMaxAge,
+		interval:    init.GraylogSpoolReplayInterval,
+		write:       write,
+		report:      report,
+	}
+
+	for _, f := range files {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), spoolSegmentExt), 10, 64)
+		if err != nil || f.IsDir() || !strings.HasSuffix(f.Name(), spoolSegmentExt) {
+			continue
+		}
+
+		s.segments = append(s.segments, &spoolSegment{seq: seq, size: int(f.Size()), modTime: f.ModTime()})
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].seq < s.segments[j].seq
+	})
+
+	if len(s.segments) > 0 {
+		s.mu.Lock()
+		s.start()
+		s.mu.Unlock()
+	}
+	return s, nil
+}
+
+// backlogged checks that there are spooled GELF messages, which are not replayed yet.
+func (s *spool) backlogged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.segments) > 0
+}
+
+// append stores a GELF message at the end of the newest segment,
+// or into a new segment if it would exceed Init.GraylogSpoolSegmentSize.
+// If Init.GraylogSpoolMaxSize is exceeded, the oldest segments are dropped and their messages are reported.
+func (s *spool) append(m GELFMessage) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	s.start()
+
+	last := s.last()
+	if s.file != nil && last.size > 0 && last.size+len(line) > s.segmentSize {
+		err = s.seal()
+	}
+	if err == nil && s.file == nil {
+		last, err = s.create()
+	}
+	if err == nil {
+		_, err = s.file.Write(line)
+		last.size += len(line)
+		last.modTime = time.Now()
+	}
+
+	dropped := s.shrink()
+	s.mu.Unlock()
+
+	for _, m := range dropped {
+		s.report(deliveryError(DeliveryDrop, errSpoolFull), m)
+	}
+	return err
+}
+
+// last returns with the newest segment, or nil if the spool is empty.
+// The caller must hold s.mu.
+func (s *spool) last() *spoolSegment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// create opens a new segment for appending.
+// The caller must hold s.mu.
+func (s *spool) create() (*spoolSegment, error) {
+	seg := &spoolSegment{seq: s.nextSeq, modTime: time.Now()}
+
+	f, err := os.OpenFile(s.path(seg), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s.nextSeq++
+	s.segments = append(s.segments, seg)
+	s.file = f
+	return seg, nil
+}
+
+// seal closes the newest segment, the next message is appended to a new one.
+// The caller must hold s.mu.
+func (s *spool) seal() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// shrink drops the oldest segments until the spool fits into Init.GraylogSpoolMaxSize,
+// and returns with their messages. The newest and the replayed segments are kept.
+// The caller must hold s.mu.
+func (s *spool) shrink() []GELFMessage {
+	size := 0
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+
+	var dropped []GELFMessage
+	for i := 0; size > s.maxSize && i < len(s.segments)-1; {
+		seg := s.segments[i]
+		if seg == s.replaying {
+			i++
+			continue
+		}
+
+		messages, _ := s.read(seg)
+		dropped = append(dropped, messages...)
+		size -= seg.size
+		s.remove(seg)
+	}
+	return dropped
+}
+
+// expire drops the segments older than Init.GraylogSpoolMaxAge, and reports their messages.
+func (s *spool) expire() {
+	if s.maxAge <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	var dropped []GELFMessage
+	for _, seg := range append([]*spoolSegment(nil), s.segments...) {
+		if seg != s.replaying && time.Since(seg.modTime) > s.maxAge {
+			messages, _ := s.read(seg)
+			dropped = append(dropped, messages...)
+			s.remove(seg)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, m := range dropped {
+		s.report(deliveryError(DeliveryDrop, errSpoolExpired), m)
+	}
+}
+
+// remove deletes a segment from the spool.
+// A segment file which could not be removed is replayed again after a restart,
+// so its error is not reported.
+// The caller must hold s.mu.
+func (s *spool) remove(seg *spoolSegment) {
+	if seg == s.last() {
+		_ = s.seal()
+	}
+	_ = os.Remove(s.path(seg))
+
+	for i, other := range s.segments {
+		if other == seg {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			return
+		}
+	}
+}
+
+// read decodes the GELF messages of a segment.
+// The lines which could not be decoded (e.g. torn by a crash) are skipped.
+func (s *spool) read(seg *spoolSegment) ([]GELFMessage, error) {
+	b, err := ioutil.ReadFile(s.path(seg))
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []GELFMessage
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		var m GELFMessage
+		if len(line) > 0 && json.Unmarshal(line, &m) == nil {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// rewrite replaces the content of a segment by the given messages.
+// The temporary file is renamed, so the segment is never left half written.
+func (s *spool) rewrite(seg *spoolSegment, messages []GELFMessage) error {
+	var buf bytes.Buffer
+	for _, m := range messages {
+		line, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp := s.path(seg) + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, s.path(seg)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	seg.size = buf.Len()
+	s.mu.Unlock()
+	return nil
+}
+
+// path returns with the file path of a segment.
+func (s *spool) path(seg *spoolSegment) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seg.seq, spoolSegmentExt))
+}
+
+// replay sends the spooled GELF messages from the oldest segment, until the spool is empty,
+// a message could not be delivered or the spool is stopped.
+// The delivered segments are removed, a partially delivered segment is rewritten with the rest of its messages.
+// The messages are delivered at least once: a segment interrupted by a crash is replayed from its beginning.
+func (s *spool) replay(stopping chan struct{}) {
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		seg := s.segments[0]
+		s.replaying = seg
+		s.mu.Unlock()
+
+		delivered := s.replaySegment(seg, stopping)
+
+		s.mu.Lock()
+		if delivered {
+			s.remove(seg)
+		}
+		s.replaying = nil
+		s.mu.Unlock()
+
+		if !delivered {
+			return
+		}
+	}
+}
+
+// replaySegment sends the messages of a segment, and reports whether all of them are delivered.
+// The newest segment is sealed only after its first message is delivered,
+// so the messages spooled during an outage are kept in as few segments as possible.
+// The messages which could not be encoded are reported and skipped.
+func (s *spool) replaySegment(seg *spoolSegment, stopping chan struct{}) bool {
+	sent, sealed := 0, false
+	for {
+		messages, err := s.read(seg)
+		if err != nil {
+			return os.IsNotExist(err)
+		}
+
+		for sent < len(messages) {
+			select {
+			case <-stopping:
+				return s.keep(seg, messages[sent:], sent)
+			default:
+			}
+
+			err := s.write(context.Background(), messages[sent])
+			if err != nil && !isDeliveryOp(err, DeliveryEncode) {
+				return s.keep(seg, messages[sent:], sent)
+			}
+			if err != nil {
+				s.report(err, messages[sent])
+			}
+
+			sent++
+			if !sealed {
+				break
+			}
+		}
+
+		if sealed {
+			return true
+		}
+
+		// The messages appended until the seal are read again.
+		s.mu.Lock()
+		if seg == s.last() {
+			_ = s.seal()
+		}
+		s.mu.Unlock()
+		sealed = true
+	}
+}
+
+// keep rewrites a segment with its messages which are not delivered yet, if any of them is delivered,
+// and returns false. If the rewrite fails, the delivered messages are replayed again.
+func (s *spool) keep(seg *spoolSegment, rest []GELFMessage, sent int) bool {
+	if sent > 0 {
+		_ = s.rewrite(seg, rest)
+	}
+	return false
+}
+
+// start launches the replayer goroutine, if it is not running yet.
+// The caller must hold s.mu.
+func (s *spool) start() {
+	if s.running {
+		return
+	}
+
+	s.running = true
+	s.stopping = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run(s.stopping, s.stopped)
+}
+
+// run replays the spool and drops its expired segments periodically, until the spool is stopped.
+func (s *spool) run(stopping, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.expire()
+		s.replay(stopping)
+
+		select {
+		case <-stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop stops the replayer and closes the newest segment.
+// The spooled messages are kept on the disk, and they are replayed by the next message or process.
+func (s *spool) stop() error {
+	s.mu.Lock()
+	running, stopping, stopped := s.running, s.stopping, s.stopped
+	s.running = false
+	s.mu.Unlock()
+
+	if running {
+		close(stopping)
+		<-stopped
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.seal()
+}
+
+// validateSpoolSize checks that given spool segment size and maximum spool size are valid or not.
+func validateSpoolSize(segmentSize, maxSize int) error {
+	if segmentSize <= 0 || maxSize < segmentSize {
+		return fmt.Errorf("invalid GELF spool size given: segment %d, maximum %d", segmentSize, maxSize)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package graylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type spoolSuite struct {
+	suite.Suite
+}
+
+func (s spoolSuite) TestSpoolAndReplay() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	reported := &reportedErrors{}
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = retrySuite{s.Suite}.freePort()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogOnError = reported.add
+	init.GraylogSpoolDir = dir
+	init.GraylogSpoolReplayInterval = 10 * time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	for i := 1; i <= 3; i++ {
+		s.Equal(nil, g.SendGELF(levelInfoNum, "spooled", i))
+	}
+	s.Equal(true, g.spool.backlogged())
+	s.Equal(0, len(reported.messages()))
+	s.Equal(nil, g.LastError())
+
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", init.GraylogPort))
+	s.Require().Equal(nil, err)
+	server := newListenerServer(graylogSuite{s.Suite}, l)
+	defer server.close()
+
+	for i := 1; i <= 3; i++ {
+		s.Equal(fmt.Sprintf("spooled :: %d", i), server.message()["short_message"])
+	}
+	s.Eventually(func() bool {
+		return !g.spool.backlogged()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Equal(nil, g.SendGELF(levelInfoNum, "direct", 4))
+	s.Equal("direct :: 4", server.message()["short_message"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+	s.Equal(0, len(s.segmentFiles(dir)))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s spoolSuite) TestSpoolSurvivesRestart() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = retrySuite{s.Suite}.freePort()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogSpoolDir = dir
+	init.GraylogSpoolReplayInterval = 10 * time.Millisecond
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("before", "restart", "second", "message")
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+	s.Equal(1, len(s.segmentFiles(dir)))
+
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", init.GraylogPort))
+	s.Require().Equal(nil, err)
+	server := newListenerServer(graylogSuite{s.Suite}, l)
+	defer server.close()
+
+	g = New(init)
+	s.Equal("before :: restart", server.message()["short_message"])
+	s.Equal("second :: message", server.message()["short_message"])
+	s.Eventually(func() bool {
+		return !g.spool.backlogged()
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal(nil, g.Close())
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s spoolSuite) TestMaxSize() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	w := &spoolWriter{failing: true}
+	reported := &reportedErrors{}
+	sp, err := openSpool(Init{
+		GraylogSpoolDir:            dir,
+		GraylogSpoolSegmentSize:    300,
+		GraylogSpoolMaxSize:        600,
+		GraylogSpoolReplayInterval: time.Hour,
+	}, w.write, reported.add)
+	s.Require().Equal(nil, err)
+
+	for i := 0; i < 20; i++ {
+		s.Equal(nil, sp.append(GELFMessage{Version: "1.1", ShortMessage: fmt.Sprint(i)}))
+	}
+
+	dropped := reported.messages()
+	s.Equal(true, len(dropped) > 0)
+	s.Equal("0", dropped[0])
+
+	size := 0
+	for _, f := range s.segmentFiles(dir) {
+		info, err := os.Stat(f)
+		s.Require().Equal(nil, err)
+		size += int(info.Size())
+	}
+	s.Equal(true, size <= 600, size)
+
+	s.Require().Equal(nil, sp.stop())
+	w.setFailing(false)
+	sp.replay(make(chan struct{}))
+	s.Equal(20, len(dropped)+len(w.delivered()))
+	s.Equal("19", w.delivered()[len(w.delivered())-1])
+}
+
+func (s spoolSuite) TestMaxAge() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	reported := &reportedErrors{}
+	var lastErr error
+	var mu sync.Mutex
+	sp, err := openSpool(Init{
+		GraylogSpoolDir:            dir,
+		GraylogSpoolSegmentSize:    graylogSpoolSegmentSize,
+		GraylogSpoolMaxSize:        graylogSpoolMaxSize,
+		GraylogSpoolMaxAge:         50 * time.Millisecond,
+		GraylogSpoolReplayInterval: 10 * time.Millisecond,
+	}, (&spoolWriter{failing: true}).write, func(err error, m GELFMessage) {
+		mu.Lock()
+		lastErr = err
+		mu.Unlock()
+		reported.add(err, m)
+	})
+	s.Require().Equal(nil, err)
+
+	s.Equal(nil, sp.append(GELFMessage{Version: "1.1", ShortMessage: "expired"}))
+	s.Eventually(func() bool {
+		return len(reported.messages()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	s.Equal(true, isDeliveryOp(lastErr, DeliveryDrop))
+	s.Equal(true, errors.Is(lastErr, errSpoolExpired))
+	mu.Unlock()
+
+	s.Equal(false, sp.backlogged())
+	s.Equal(nil, sp.stop())
+}
+
+func (s spoolSuite) TestPartialReplay() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	w := &spoolWriter{failAfter: 2}
+	sp, err := openSpool(Init{
+		GraylogSpoolDir:            dir,
+		GraylogSpoolSegmentSize:    graylogSpoolSegmentSize,
+		GraylogSpoolMaxSize:        graylogSpoolMaxSize,
+		GraylogSpoolReplayInterval: time.Hour,
+	}, w.write, nil)
+	s.Require().Equal(nil, err)
+
+	for i := 0; i < 5; i++ {
+		s.Equal(nil, sp.append(GELFMessage{Version: "1.1", ShortMessage: fmt.Sprint(i)}))
+	}
+
+	// 1. The first two messages are delivered, the rest is kept in the segment ...
+	s.Require().Equal(nil, sp.stop())
+	sp.replay(make(chan struct{}))
+	s.Equal([]string{"0", "1"}, w.delivered())
+	s.Equal(true, sp.backlogged())
+
+	sp.mu.Lock()
+	messages, err := sp.read(sp.segments[0])
+	sp.mu.Unlock()
+	s.Equal(nil, err)
+	s.Equal(3, len(messages))
+
+	// 2. ... the messages spooled meanwhile are appended to a new segment ...
+	s.Equal(nil, sp.append(GELFMessage{Version: "1.1", ShortMessage: "5"}))
+	s.Equal(2, len(s.segmentFiles(dir)))
+
+	// 3. ... and all of them are delivered in order.
+	s.Require().Equal(nil, sp.stop())
+	w.setFailing(false)
+	sp.replay(make(chan struct{}))
+	s.Equal([]string{"0", "1", "2", "3", "4", "5"}, w.delivered())
+	s.Equal(false, sp.backlogged())
+	s.Equal(0, len(s.segmentFiles(dir)))
+}
+
+func (s spoolSuite) TestInitSpool() {
+	dir := s.tempDir()
+	defer os.RemoveAll(dir)
+
+	g := New(Init{GraylogProtocol: TransportTCP, GraylogSpoolDir: dir, LogLevel: LevelDebug})
+	s.Equal(graylogSpoolSegmentSize, g.GetInit().GraylogSpoolSegmentSize)
+	s.Equal(graylogSpoolMaxSize, g.GetInit().GraylogSpoolMaxSize)
+	s.Equal(time.Duration(0), g.GetInit().GraylogSpoolMaxAge)
+	s.Equal(graylogSpoolReplayInterval, g.GetInit().GraylogSpoolReplayInterval)
+	s.Equal(false, g.spool.backlogged())
+	s.Equal(nil, g.Close())
+
+	file := filepath.Join(dir, "file")
+	s.Require().Equal(nil, ioutil.WriteFile(file, nil, 0600))
+	_, err := openSpool(Init{GraylogSpoolDir: file}, nil, nil)
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(err), "could not create GELF spool directory: "))
+
+	s.Equal(nil, validateSpoolSize(100, 100))
+	s.Equal("invalid GELF spool size given: segment 0, maximum 100", fmt.Sprint(validateSpoolSize(0, 100)))
+	s.Equal("invalid GELF spool size given: segment 200, maximum 100", fmt.Sprint(validateSpoolSize(200, 100)))
+}
+
+func (s spoolSuite) tempDir() string {
+	dir, err := ioutil.TempDir("", "graylogger-spool")
+	s.Require().Equal(nil, err)
+	return dir
+}
+
+func (s spoolSuite) segmentFiles(dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	s.Require().Equal(nil, err)
+	return files
+}
+
+// spoolWriter records the replayed short messages,
+// it fails while failing is set or after failAfter messages are delivered.
+type spoolWriter struct {
+	mu        sync.Mutex
+	failing   bool
+	failAfter int
+	messages  []string
+}
+
+func (w *spoolWriter) write(_ context.Context, m GELFMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failing || (w.failAfter > 0 && len(w.messages) >= w.failAfter) {
+		return deliveryError(DeliveryConnect, errors.New("connection refused"))
+	}
+	w.messages = append(w.messages, m.ShortMessage)
+	return nil
+}
+
+func (w *spoolWriter) setFailing(failing bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.failing = failing
+	w.failAfter = 0
+}
+
+func (w *spoolWriter) delivered() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.messages...)
+}
+
+func TestSpoolSuite(t *testing.T) {
+	suite.Run(t, new(spoolSuite))
+}
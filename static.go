@@ -0,0 +1,44 @@
+package graylogger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// staticFields returns with Init.GraylogStaticFields as key : value pairs in the order of their names.
+// The leading underscore of the names is optional, it is removed.
+func (i Init) staticFields() []keyValue {
+	if len(i.GraylogStaticFields) == 0 {
+		return nil
+	}
+
+	pairs := make([]keyValue, 0, len(i.GraylogStaticFields))
+	for name, value := range i.GraylogStaticFields {
+		pairs = append(pairs, keyValue{key: strings.TrimPrefix(name, "_"), value: value})
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].key < pairs[b].key })
+
+	return pairs
+}
+
+// validateStaticFields checks that the names of given static fields are valid GELF additional field names or not:
+// they may contain letters, digits, underscores, dashes and dots only, they can not be "_id" or a field of GraylogExtraFields,
+// and a name can not be given both with and without the leading underscore.
+func validateStaticFields(fields map[string]interface{}) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := strings.TrimPrefix(name, "_")
+		_, reserved := reservedExtraFields[field]
+		_, duplicated := fields["_"+name]
+		if field == "" || field == "id" || reserved || (duplicated && field == name) || gelfFieldName(field) != field {
+			return fmt.Errorf("invalid GELF static field name given: %s", name)
+		}
+	}
+	return nil
+}
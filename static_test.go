@@ -0,0 +1,111 @@
+package graylogger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type staticSuite struct {
+	suite.Suite
+}
+
+func (s staticSuite) TestStructuredMessage() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStructured = true
+	init.GraylogStaticFields = map[string]interface{}{"_datacenter": "eu-1", "version": "1.2.3", "_pod": "api-7", "replicas": 3}
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42, "pod", "other")
+	g.SaveOutput()
+	s.Equal(nil, g.Close())
+
+	m := server.message()
+	s.Equal("eu-1", m["_datacenter"])
+	s.Equal("1.2.3", m["_version"])
+	s.Equal("api-7", m["_pod"])
+	s.Equal("3", m["_replicas"])
+	s.Equal("other", m["_pod_2"])
+	s.Equal("42", m["_user"])
+	s.Equal("user :: 42 :: pod :: other", m["short_message"])
+
+	s.Equal(true, strings.HasSuffix(g.GetOutput(), "[user :: 42 :: pod :: other]\n"), g.GetOutput())
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s staticSuite) TestMessagePerPair() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogStaticFields = map[string]interface{}{"_git_sha": "a1b2c3d"}
+	init.GraylogGELFBuilder = RenameGELFFields(map[string]string{"git_sha": "commit"})
+
+	g := New(init)
+	g.Warning("user", 42, "status", "failed")
+	s.Equal(nil, g.Close())
+
+	for i := 0; i < 2; i++ {
+		m := server.message()
+		s.Equal("a1b2c3d", m["_commit"])
+		s.Equal(nil, m["_git_sha"])
+	}
+}
+
+func (s staticSuite) TestLogStaticFields() {
+	init := testInit
+	init.LogFormat = FormatLogfmt
+	init.LogStaticFields = true
+	init.GraylogStaticFields = map[string]interface{}{"_version": "1.2.3", "datacenter": "eu-1"}
+
+	g := New(init).With("component", "db")
+	g.CaptureOutput(testOutputFileName)
+	g.Info("user", 42)
+	g.SaveOutput()
+
+	s.Equal(true, strings.HasSuffix(g.GetOutput(), " user=42 component=db datacenter=eu-1 version=1.2.3\n"), g.GetOutput())
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s staticSuite) TestValidateStaticFields() {
+	s.Equal(nil, validateStaticFields(nil))
+	s.Equal(nil, validateStaticFields(map[string]interface{}{"_datacenter": 1, "git-sha": 2, "app.version": 3, "_pod_name": 4, "__pod_name": 5}))
+
+	for _, name := range []string{"_id", "id", "", "_", "data center", "pod/name", "árvíztűrő", "_log_env", "track_line", "truncated"} {
+		err := validateStaticFields(map[string]interface{}{"valid": 1, name: 2})
+		s.Equal("invalid GELF static field name given: "+name, fmt.Sprint(err), name)
+	}
+
+	err := validateStaticFields(map[string]interface{}{"_pod": 1, "pod": 2})
+	s.Equal("invalid GELF static field name given: pod", fmt.Sprint(err))
+
+	s.Equal([]keyValue{{"datacenter", "eu-1"}, {"pod", "api-7"}, {"version", 2}},
+		Init{GraylogStaticFields: map[string]interface{}{"version": 2, "_pod": "api-7", "_datacenter": "eu-1"}}.staticFields())
+	s.Equal([]keyValue(nil), Init{}.staticFields())
+
+	g := New(Init{GraylogStaticFields: map[string]interface{}{"_pod": "api-7"}}).With("component", "db")
+	s.Equal([]keyValue{{"pod", "api-7"}}, g.static)
+}
+
+func TestStaticSuite(t *testing.T) {
+	suite.Run(t, new(staticSuite))
+}
@@ -0,0 +1,102 @@
+package graylogger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// initTLS sets the defaults of the TLS transport and creates its configuration.
+func (g *GrayLogger) initTLS() {
+	if g.initData.GraylogTLSMinVersion == 0 {
+		g.initData.GraylogTLSMinVersion = tls.VersionTLS12
+	}
+
+	config, err := g.initData.newTLSConfig()
+	if err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+	g.tlsConfig = config
+}
+
+// newTLSConfig creates the TLS configuration of the TransportTLS
+// from the CA bundle, client certificate and server name of the Init.
+func (i Init) newTLSConfig() (*tls.Config, error) {
+	if err := validateTLSVersion(i.GraylogTLSMinVersion); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName: i.GraylogTLSServerName,
+		MinVersion: i.GraylogTLSMinVersion,
+	}
+
+	if i.GraylogTLSCAFile != "" {
+		ca, err := ioutil.ReadFile(i.GraylogTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read TLS CA file: %s", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in TLS CA file: %s", i.GraylogTLSCAFile)
+		}
+	}
+
+	if i.GraylogTLSCertFile != "" || i.GraylogTLSKeyFile != "" {
+		if i.GraylogTLSCertFile == "" || i.GraylogTLSKeyFile == "" {
+			return nil, fmt.Errorf("both TLS certificate and key files must be given for mutual TLS")
+		}
+
+		cert, err := tls.LoadX509KeyPair(i.GraylogTLSCertFile, i.GraylogTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load TLS client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// connectTLS opens the connection of the Graylog endpoint secured by TLS,
+// the dial and the TLS handshake are given up when the context is done.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) connectTLS(ctx context.Context, e *endpoint) error {
+	c, err := (&net.Dialer{}).DialContext(ctx, "tcp", e.String())
+	if err != nil {
+		return err
+	}
+
+	config := g.tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = e.Host
+	}
+
+	tlsConn := tls.Client(c, config)
+	release := watchContext(ctx, c)
+	err = tlsConn.Handshake()
+	release()
+
+	if err != nil {
+		_ = c.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	e.sender = &streamSender{conn: tlsConn}
+	return nil
+}
+
+// validateTLSVersion checks that given minimum TLS version is valid or not.
+func validateTLSVersion(version uint16) error {
+	switch version {
+	case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		return nil
+	}
+	return fmt.Errorf("invalid TLS version given: %#x", version)
+}
@@ -0,0 +1,230 @@
+package graylogger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type tlsSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *tlsSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "graylogger-tls")
+	s.Require().Equal(nil, err)
+	s.dir = dir
+}
+
+func (s *tlsSuite) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *tlsSuite) TestSendGELFOverTLS() {
+	ca := s.newCertificate("ca", nil)
+	serverCert := s.newCertificate("server", ca)
+	clientCert := s.newCertificate("client", ca)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.x509)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert.tls},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	s.Require().Equal(nil, err)
+
+	server := newListenerServer(graylogSuite{s.Suite}, listener)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTLS
+	init.GraylogTLSCAFile = ca.certFile
+	init.GraylogTLSCertFile = clientCert.certFile
+	init.GraylogTLSKeyFile = clientCert.keyFile
+	init.GraylogTLSServerName = "graylog.test"
+	init.GraylogTLSMinVersion = tls.VersionTLS13
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "tls")
+	g.Info("test", "reused")
+
+	s.Equal("test :: tls", server.message()["short_message"])
+	s.Equal("test :: reused", server.message()["short_message"])
+	s.Equal(1, server.accepted())
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s *tlsSuite) TestSendGELFOverTLSUnknownAuthority() {
+	ca := s.newCertificate("ca", nil)
+	otherCA := s.newCertificate("other-ca", nil)
+	serverCert := s.newCertificate("server", otherCA)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert.tls},
+	})
+	s.Require().Equal(nil, err)
+
+	server := newListenerServer(graylogSuite{s.Suite}, listener)
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTLS
+	init.GraylogTLSCAFile = ca.certFile
+	init.GraylogTLSServerName = "graylog.test"
+	init.GraylogTimeout = time.Second
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", "tls")
+	g.SaveOutput()
+
+	s.Equal(true, strings.Contains(g.GetOutput(), "could not connect to Graylog host"))
+
+	err = os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s *tlsSuite) TestNewTLSConfig() {
+	ca := s.newCertificate("ca", nil)
+	clientCert := s.newCertificate("client", ca)
+
+	// 1. Defaults ...
+	g := New(Init{GraylogProtocol: TransportTLS, LogLevel: LevelDebug})
+	s.Equal(uint16(tls.VersionTLS12), g.GetInit().GraylogTLSMinVersion)
+	s.Equal(uint16(tls.VersionTLS12), g.tlsConfig.MinVersion)
+	s.Equal((*x509.CertPool)(nil), g.tlsConfig.RootCAs)
+
+	// 2. CA bundle, client certificate and server name ...
+	config, err := Init{
+		GraylogTLSCAFile:     ca.certFile,
+		GraylogTLSCertFile:   clientCert.certFile,
+		GraylogTLSKeyFile:    clientCert.keyFile,
+		GraylogTLSServerName: "graylog.test",
+		GraylogTLSMinVersion: tls.VersionTLS13,
+	}.newTLSConfig()
+	s.Equal(nil, err)
+	s.NotEqual((*x509.CertPool)(nil), config.RootCAs)
+	s.Equal(1, len(config.Certificates))
+	s.Equal("graylog.test", config.ServerName)
+	s.Equal(uint16(tls.VersionTLS13), config.MinVersion)
+
+	// 3. Invalid TLS version ...
+	_, err = Init{GraylogTLSMinVersion: 0x1234}.newTLSConfig()
+	s.Equal("invalid TLS version given: 0x1234", fmt.Sprint(err))
+
+	// 4. Missing CA file ...
+	_, err = Init{GraylogTLSCAFile: filepath.Join(s.dir, "missing.pem"), GraylogTLSMinVersion: tls.VersionTLS12}.newTLSConfig()
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(err), "could not read TLS CA file"))
+
+	// 5. CA file without certificates ...
+	_, err = Init{GraylogTLSCAFile: clientCert.keyFile, GraylogTLSMinVersion: tls.VersionTLS12}.newTLSConfig()
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(err), "no valid certificate found in TLS CA file"))
+
+	// 6. Client certificate without key ...
+	_, err = Init{GraylogTLSCertFile: clientCert.certFile, GraylogTLSMinVersion: tls.VersionTLS12}.newTLSConfig()
+	s.Equal("both TLS certificate and key files must be given for mutual TLS", fmt.Sprint(err))
+
+	// 7. Mismatching client certificate and key ...
+	_, err = Init{GraylogTLSCertFile: clientCert.certFile, GraylogTLSKeyFile: ca.keyFile, GraylogTLSMinVersion: tls.VersionTLS12}.newTLSConfig()
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(err), "could not load TLS client certificate"))
+}
+
+func (s *tlsSuite) TestValidateTransport() {
+	s.Equal(nil, TransportTLS.validateTransport())
+}
+
+// testCertificate holds a generated certificate and the PEM files where it is saved.
+type testCertificate struct {
+	x509     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	tls      tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// newCertificate generates a certificate for 127.0.0.1 and graylog.test,
+// signed by the given CA, or a self-signed CA certificate if ca is nil.
+func (s *tlsSuite) newCertificate(name string, ca *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().Equal(nil, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"graylog.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parent, signer = ca.x509, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	s.Require().Equal(nil, err)
+
+	cert, err := x509.ParseCertificate(der)
+	s.Require().Equal(nil, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	s.Require().Equal(nil, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	t := &testCertificate{
+		x509:     cert,
+		key:      key,
+		certFile: filepath.Join(s.dir, name+".crt"),
+		keyFile:  filepath.Join(s.dir, name+".key"),
+	}
+
+	s.Require().Equal(nil, ioutil.WriteFile(t.certFile, certPEM, 0600))
+	s.Require().Equal(nil, ioutil.WriteFile(t.keyFile, keyPEM, 0600))
+
+	t.tls, err = tls.X509KeyPair(certPEM, keyPEM)
+	s.Require().Equal(nil, err)
+
+	return t
+}
+
+func TestTLSSuite(t *testing.T) {
+	suite.Run(t, new(tlsSuite))
+}
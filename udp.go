@@ -0,0 +1,169 @@
+package graylogger
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+)
+
+const (
+	// graylogUDPChunkSize declares the default maximum size of a GELF UDP datagram,
+	// which fits into the MTU of the most networks.
+	graylogUDPChunkSize = 1420
+
+	// gelfChunkHeaderSize is the size of the header of a GELF chunk:
+	// magic bytes (2), message ID (8), sequence number (1) and sequence count (1).
+	gelfChunkHeaderSize = 12
+
+	// gelfMaxChunks is the maximum number of chunks of a GELF message accepted by Graylog.
+	gelfMaxChunks = 128
+
+	// maxUDPPayloadSize is the maximum size of an UDP datagram payload.
+	maxUDPPayloadSize = 65507
+)
+
+// gelfChunkMagic are the first two bytes of every GELF chunk.
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// ChunkLimitPolicy declares what happens with a GELF UDP message, which does not fit into 128 chunks.
+type ChunkLimitPolicy string
+
+const (
+	// ChunkLimitError drops the GELF message and the send returns with an error.
+	ChunkLimitError ChunkLimitPolicy = "error"
+
+	// ChunkLimitTruncate trims the GELF message, until it fits into 128 chunks.
+	ChunkLimitTruncate ChunkLimitPolicy = "truncate"
+)
+
+// udpSender sends GELF messages in UDP datagrams,
+// the messages larger than the chunk size are split into GELF chunks.
+type udpSender struct {
+	mu               sync.Mutex
+	conn             net.Conn
+	chunkSize        int
+	compression      Compression
+	compressionLevel int
+}
+
+// initUDP sets the defaults of the GELF UDP transport.
+func (g *GrayLogger) initUDP() {
+	if g.initData.GraylogUDPChunkSize == 0 {
+		g.initData.GraylogUDPChunkSize = graylogUDPChunkSize
+	}
+
+	if g.initData.GraylogUDPChunkLimit == "" {
+		g.initData.GraylogUDPChunkLimit = ChunkLimitError
+	}
+
+	if err := validateUDPChunkSize(g.initData.GraylogUDPChunkSize); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+
+	if err := g.initData.GraylogUDPChunkLimit.validateChunkLimitPolicy(); err != nil && g.isSetGraylogObligatoryFields() {
+		g.Fatal(err)
+	}
+}
+
+// connectUDP creates the GELF UDP sender of the Graylog endpoint.
+// The caller must hold the lock of g.conn.
+func (g *GrayLogger) connectUDP(ctx context.Context, e *endpoint) error {
+	c, err := (&net.Dialer{}).DialContext(ctx, "udp", e.String())
+	if err != nil {
+		return err
+	}
+
+	e.sender = &udpSender{
+		conn:             c,
+		chunkSize:        g.initData.GraylogUDPChunkSize,
+		compression:      g.initData.GraylogCompression,
+		compressionLevel: g.initData.GraylogCompressionLevel,
+	}
+	return nil
+}
+
+// Send writes a GELF message into one datagram, or into GELF chunks if it is larger than the chunk size.
+// The message is compressed before it is chunked.
+func (u *udpSender) Send(ctx context.Context, m GELFMessage) error {
+	payload, err := encodeGELF(m)
+	if err != nil {
+		return deliveryError(DeliveryEncode, err)
+	}
+
+	payload, err = u.compression.compress(payload, u.compressionLevel)
+	if err != nil {
+		return deliveryError(DeliveryEncode, err)
+	}
+
+	// The deadline of the context is applied to the whole connection, so the messages are written one by one.
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	release := watchContext(ctx, u.conn)
+	defer release()
+
+	if len(payload) <= u.chunkSize {
+		_, err = u.conn.Write(payload)
+		return err
+	}
+
+	return u.sendChunks(payload)
+}
+
+// sendChunks splits the payload into GELF chunks, which are sent one by one.
+// For example, the header of the second chunk out of three:
+//  0x1e 0x0f | 8 bytes message ID | 0x01 | 0x03
+func (u *udpSender) sendChunks(payload []byte) error {
+	dataSize := u.chunkSize - gelfChunkHeaderSize
+	count := (len(payload) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return deliveryError(DeliveryEncode, fmt.Errorf("GELF message of %d bytes does not fit into %d chunks of %d bytes", len(payload), gelfMaxChunks, u.chunkSize))
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, u.chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, payload[i*dataSize:end]...)
+
+		if _, err := u.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the UDP socket.
+func (u *udpSender) Close() error {
+	return u.conn.Close()
+}
+
+// validateUDPChunkSize checks that given GELF UDP chunk size is valid or not.
+func validateUDPChunkSize(size int) error {
+	if size <= gelfChunkHeaderSize || size > maxUDPPayloadSize {
+		return fmt.Errorf("invalid GELF UDP chunk size given: %d", size)
+	}
+	return nil
+}
+
+// validateChunkLimitPolicy checks that given chunk limit policy is valid or not.
+func (p ChunkLimitPolicy) validateChunkLimitPolicy() error {
+	switch p {
+	case ChunkLimitError, ChunkLimitTruncate:
+		return nil
+	}
+	return fmt.Errorf("invalid chunk limit policy given: %s", p)
+}
@@ -0,0 +1,248 @@
+package graylogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type udpSuite struct {
+	suite.Suite
+}
+
+func (s udpSuite) TestSendGELFChunks() {
+	server := newChunkServer(s)
+	defer server.close()
+
+	init := server.init()
+	init.GraylogUDPChunkSize = 500
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", strings.Repeat("chunked ", 200))
+
+	obj, chunks := server.message()
+	s.Equal(true, chunks > 1)
+	s.Equal("test :: "+strings.Repeat("chunked ", 200), obj["full_message"])
+	s.Equal(nil, obj["_truncated"])
+
+	g.Info("test", "single")
+	obj, chunks = server.message()
+	s.Equal(0, chunks)
+	s.Equal("test :: single", obj["short_message"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s udpSuite) TestChunkLimitTruncate() {
+	server := newChunkServer(s)
+	defer server.close()
+
+	init := server.init()
+	init.GraylogUDPChunkSize = 20
+	init.GraylogUDPChunkLimit = ChunkLimitTruncate
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", strings.Repeat("x", 2000))
+
+	obj, chunks := server.message()
+	s.Equal(gelfMaxChunks, chunks)
+	s.Equal("true", obj["_truncated"])
+	s.Equal(true, len(fmt.Sprint(obj["full_message"])) < 2000)
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s udpSuite) TestChunkLimitError() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	c, err := net.Dial("udp", conn.LocalAddr().String())
+	s.Require().Equal(nil, err)
+
+	sender := &udpSender{conn: c, chunkSize: 20}
+	err = sender.Send(context.Background(), GELFMessage{Version: "1.1", ShortMessage: strings.Repeat("x", 2000)})
+	s.Equal(true, isDeliveryOp(err, DeliveryEncode))
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(errors.Unwrap(err)), "GELF message of "))
+	s.Equal(true, strings.HasSuffix(fmt.Sprint(errors.Unwrap(err)), " bytes does not fit into 128 chunks of 20 bytes"))
+	s.Equal(nil, sender.Close())
+}
+
+func (s udpSuite) TestMaxMessageSize() {
+	server := newTCPServer(graylogSuite{s.Suite})
+	defer server.close()
+
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = server.port()
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportTCP
+	init.GraylogMaxMessageSize = 400
+
+	g := New(init)
+	g.CaptureOutput(testOutputFileName)
+	g.Info("test", strings.Repeat("long ", 200))
+	g.Info("test", "short")
+
+	obj := server.message()
+	s.Equal("true", obj["_truncated"])
+	s.Equal(true, strings.HasPrefix(fmt.Sprint(obj["full_message"]), "test :: long long"))
+	s.Equal("info", obj["_log_level"])
+
+	obj = server.message()
+	s.Equal(nil, obj["_truncated"])
+	s.Equal("test :: short", obj["full_message"])
+
+	s.Equal(nil, g.Close())
+	g.SaveOutput()
+
+	err := os.Remove(testOutputFileName)
+	s.Equal(nil, err)
+}
+
+func (s udpSuite) TestTruncateGELF() {
+	size := func(m GELFMessage) int {
+		payload, err := encodeGELF(m)
+		s.Require().Equal(nil, err)
+		return len(payload)
+	}
+
+	// 1. Fitting messages are not changed ...
+	m := GELFMessage{Version: "1.1", ShortMessage: "short", FullMessage: "full", Extra: map[string]string{"key": "value"}}
+	s.Equal(m, truncateGELF(m, size(m)))
+
+	// 2. The long full message is trimmed, without splitting multi-byte characters ...
+	m.FullMessage = strings.Repeat("ő", 100)
+	truncated := truncateGELF(m, 150)
+	s.Equal(true, size(truncated) <= 150)
+	s.Equal(true, strings.HasPrefix(m.FullMessage, truncated.FullMessage))
+	s.Equal(0, len(truncated.FullMessage)%len("ő"))
+	s.Equal("short", truncated.ShortMessage)
+	s.Equal("true", truncated.Extra[truncatedField])
+	s.Equal("value", truncated.Extra["key"])
+	s.Equal("", m.Extra[truncatedField])
+
+	// 3. ... the long values are cut to the same length, the short ones are kept.
+	m.FullMessage = strings.Repeat("f", 200)
+	m.ShortMessage = strings.Repeat("s", 200)
+	m.Extra = map[string]string{"long": strings.Repeat("l", 200), "key": "value"}
+	truncated = truncateGELF(m, 250)
+	s.Equal(true, size(truncated) <= 250)
+	s.Equal(true, size(truncated) > 240)
+	s.Equal("value", truncated.Extra["key"])
+	s.Equal(len(truncated.FullMessage), len(truncated.ShortMessage))
+	s.Equal(len(truncated.ShortMessage), len(truncated.Extra["long"]))
+	s.Equal(200, len(m.Extra["long"]))
+}
+
+func (s udpSuite) TestInitUDP() {
+	g := New(Init{GraylogProtocol: TransportUDP, LogLevel: LevelDebug})
+	s.Equal(graylogUDPChunkSize, g.GetInit().GraylogUDPChunkSize)
+	s.Equal(ChunkLimitError, g.GetInit().GraylogUDPChunkLimit)
+	s.Equal(0, g.maxMessageSize())
+
+	g = New(Init{GraylogProtocol: TransportUDP, GraylogUDPChunkLimit: ChunkLimitTruncate, LogLevel: LevelDebug})
+	s.Equal(gelfMaxChunks*(graylogUDPChunkSize-gelfChunkHeaderSize), g.maxMessageSize())
+
+	g = New(Init{GraylogProtocol: TransportUDP, GraylogUDPChunkLimit: ChunkLimitTruncate, GraylogMaxMessageSize: 1000, LogLevel: LevelDebug})
+	s.Equal(1000, g.maxMessageSize())
+
+	s.Equal(nil, validateUDPChunkSize(graylogUDPChunkSize))
+	s.Equal("invalid GELF UDP chunk size given: 12", fmt.Sprint(validateUDPChunkSize(gelfChunkHeaderSize)))
+	s.Equal("invalid GELF UDP chunk size given: 65508", fmt.Sprint(validateUDPChunkSize(maxUDPPayloadSize+1)))
+
+	s.Equal(nil, ChunkLimitError.validateChunkLimitPolicy())
+	s.Equal(nil, ChunkLimitTruncate.validateChunkLimitPolicy())
+	s.Equal("invalid chunk limit policy given: bad_policy", fmt.Sprint(ChunkLimitPolicy("bad_policy").validateChunkLimitPolicy()))
+}
+
+// chunkServer is a GELF UDP input for tests, which reassembles the chunked messages.
+type chunkServer struct {
+	s    udpSuite
+	conn net.PacketConn
+}
+
+func newChunkServer(s udpSuite) *chunkServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().Equal(nil, err)
+	return &chunkServer{s: s, conn: conn}
+}
+
+func (c *chunkServer) init() Init {
+	init := testInit
+	init.GraylogHost = "127.0.0.1"
+	init.GraylogPort = c.conn.LocalAddr().(*net.UDPAddr).Port
+	init.GraylogProvider = "TestService"
+	init.GraylogProtocol = TransportUDP
+	return init
+}
+
+// message reads the next GELF message and returns it with the number of its chunks,
+// which is 0 if the message was not chunked.
+func (c *chunkServer) message() (map[string]interface{}, int) {
+	c.s.Require().Equal(nil, c.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var id []byte
+	var chunks [][]byte
+	for received := 0; ; received++ {
+		datagram := make([]byte, maxUDPPayloadSize)
+		n, _, err := c.conn.ReadFrom(datagram)
+		c.s.Require().Equal(nil, err)
+		datagram = datagram[:n]
+
+		if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+			return c.decode(datagram), 0
+		}
+
+		c.s.Require().Equal(true, len(datagram) >= gelfChunkHeaderSize)
+		if id == nil {
+			id = datagram[2:10]
+			chunks = make([][]byte, datagram[11])
+		}
+		c.s.Equal(id, datagram[2:10])
+		c.s.Equal(len(chunks), int(datagram[11]))
+		chunks[datagram[10]] = datagram[gelfChunkHeaderSize:]
+
+		if received+1 == len(chunks) {
+			return c.decode(bytes.Join(chunks, nil)), len(chunks)
+		}
+	}
+}
+
+func (c *chunkServer) decode(payload []byte) map[string]interface{} {
+	payload, err := decompressGELF(payload)
+	c.s.Require().Equal(nil, err)
+
+	obj := map[string]interface{}{}
+	c.s.Equal(nil, json.Unmarshal(payload, &obj))
+	return obj
+}
+
+func (c *chunkServer) close() {
+	_ = c.conn.Close()
+}
+
+func TestUDPSuite(t *testing.T) {
+	suite.Run(t, new(udpSuite))
+}